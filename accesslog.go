@@ -0,0 +1,270 @@
+package boilerplate
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type accessLogKey struct{}
+
+// accessLogEntry collects what only handlers further in know, like the
+// authenticated subject or the matched route
+type accessLogEntry struct {
+	mu      sync.Mutex
+	subject string
+	route   string
+}
+
+func accessLogEntryFromContext(ctx context.Context) *accessLogEntry {
+	entry, _ := ctx.Value(accessLogKey{}).(*accessLogEntry)
+	return entry
+}
+
+func (e *accessLogEntry) setSubject(subject string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subject = subject
+}
+
+func (e *accessLogEntry) setRoute(route string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.route = route
+}
+
+func (e *accessLogEntry) get() (subject, route string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.subject, e.route
+}
+
+// accessLog logs one record per grpc call and gateway request. The trace id
+// is added by the logger.
+type accessLog struct {
+	conf   AccessLogConfig
+	logger *slog.Logger
+}
+
+func newAccessLog(conf AccessLogConfig, logger *slog.Logger) *accessLog {
+	return &accessLog{conf: conf, logger: logger}
+}
+
+func (a *accessLog) excluded(names ...string) bool {
+	for _, pattern := range a.conf.Exclude {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok && name != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sampled always logs failed requests, successful ones by the configured ratio
+func (a *accessLog) sampled(success bool) bool {
+	return !success || rand.Float64() < a.conf.SuccessRatio
+}
+
+func (a *accessLog) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if a.excluded(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		entry := &accessLogEntry{}
+		start := time.Now()
+		resp, err := handler(context.WithValue(ctx, accessLogKey{}, entry), req)
+		a.logCall(ctx, entry, info.FullMethod, status.Code(err), time.Since(start), messageSize(req), messageSize(resp))
+		return resp, err
+	}
+}
+
+func (a *accessLog) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.excluded(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		entry := &accessLogEntry{}
+		stream := &accessLogStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), accessLogKey{}, entry)}
+		start := time.Now()
+		err := handler(srv, stream)
+		a.logCall(ss.Context(), entry, info.FullMethod, status.Code(err), time.Since(start), stream.received, stream.sent)
+		return err
+	}
+}
+
+func (a *accessLog) logCall(ctx context.Context, entry *accessLogEntry, fullMethod string, code codes.Code, duration time.Duration, received, sent int) {
+	if !a.sampled(code == codes.OK) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("rpc.method", fullMethod),
+		slog.String("rpc.grpc.status_code", code.String()),
+		slog.Duration("duration", duration),
+		slog.Int("request.size", received),
+		slog.Int("response.size", sent),
+	}
+	if addr := clientAddress(ctx); addr != "" {
+		attrs = append(attrs, slog.String("client.address", addr))
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md["user-agent"]) > 0 {
+		attrs = append(attrs, slog.String("user_agent.original", md["user-agent"][0]))
+	}
+	if subject, _ := entry.get(); subject != "" {
+		attrs = append(attrs, slog.String("user.id", subject))
+	}
+
+	a.logger.LogAttrs(ctx, accessLogLevel(runtime.HTTPStatusFromCode(code)), "grpc request", attrs...)
+}
+
+func (a *accessLog) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.excluded(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &accessLogEntry{}
+		writer := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+		body := &countingReader{ReadCloser: r.Body}
+		req := r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
+		req.Body = body
+
+		start := time.Now()
+		h.ServeHTTP(writer, req)
+		duration := time.Since(start)
+
+		subject, route := entry.get()
+		if a.excluded(route) || !a.sampled(writer.status < http.StatusBadRequest) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("http.request.method", r.Method),
+			slog.String("url.path", r.URL.Path),
+			slog.Int("http.response.status_code", writer.status),
+			slog.Duration("duration", duration),
+			slog.Int("request.size", body.n),
+			slog.Int("response.size", writer.n),
+			slog.String("client.address", r.RemoteAddr),
+		}
+		if route != "" {
+			attrs = append(attrs, slog.String("http.route", route))
+		}
+		if ua := r.UserAgent(); ua != "" {
+			attrs = append(attrs, slog.String("user_agent.original", ua))
+		}
+		if subject != "" {
+			attrs = append(attrs, slog.String("user.id", subject))
+		}
+
+		a.logger.LogAttrs(r.Context(), accessLogLevel(writer.status), "http request", attrs...)
+	})
+}
+
+func accessLogLevel(httpStatus int) slog.Level {
+	if httpStatus >= http.StatusInternalServerError {
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func messageSize(msg any) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
+
+// accessLogStream sums up the size of the messages of a stream
+type accessLogStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+	sent     int
+}
+
+func (s *accessLogStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *accessLogStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received += messageSize(m)
+	}
+	return err
+}
+
+func (s *accessLogStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent += messageSize(m)
+	}
+	return err
+}
+
+type accessLogWriter struct {
+	http.ResponseWriter
+	status      int
+	n           int
+	wroteHeader bool
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.n += n
+	return n, err
+}
+
+// Flush keeps server streaming through the gateway working
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += n
+	return n, err
+}
@@ -0,0 +1,78 @@
+package boilerplate
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// runAdmin serves operational endpoints on their own listener, so they are
+// not exposed through the public gateway
+func (s *boilerplate) runAdmin(conf BoilerplateConfig) error {
+	mux := http.NewServeMux()
+
+	if s.metricsHandler != nil {
+		mux.Handle(conf.Otel.Prometheus.path(), s.metricsHandler)
+	}
+	mux.HandleFunc("/loglevel", s.logLevelHandler(conf.Admin.Token))
+
+	server := &http.Server{
+		Addr:              conf.Admin.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: DEFAULT_GATEWAY_READ_HEADER_TIMEOUT,
+	}
+	s.logger.Info("starting admin server", "addr", conf.Admin.Addr)
+	return server.ListenAndServe()
+}
+
+// logLevelHandler returns the log level on GET and changes it on PUT, with
+// the level as plain text body (curl -X PUT -d debug .../loglevel). PUT
+// requires the token as bearer token and is rejected if none is configured.
+func (s *boilerplate) logLevelHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if token == "" {
+				http.Error(w, "changing the log level requires admin.token", http.StatusForbidden)
+				return
+			}
+			if !validAdminToken(r, token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var level slog.Level
+			if err := level.UnmarshalText(bytes.TrimSpace(body)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			s.mu.Lock()
+			s.config.Log.Level = strings.ToLower(level.String())
+			s.mu.Unlock()
+			s.logLevel.Set(level)
+			s.Logger().InfoContext(r.Context(), "log level changed", "level", level)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, strings.ToLower(s.logLevel.Level().String()))
+	}
+}
+
+func validAdminToken(r *http.Request, token string) bool {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+}
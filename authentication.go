@@ -13,6 +13,8 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+type claimsKey struct{}
+
 func UnaryJwtClaimsInterceptor[T jwt.Claims](jwksUrls []string, claimsFunc func() T, requireAuthn bool) (grpc.UnaryServerInterceptor, error) {
 
 	Keyfunc, err := keyfunc.NewDefault(jwksUrls)
@@ -21,42 +23,74 @@ func UnaryJwtClaimsInterceptor[T jwt.Claims](jwksUrls []string, claimsFunc func(
 	}
 
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, errMissingMetadata
+		ctx, err := authenticate(ctx, Keyfunc, claimsFunc, requireAuthn)
+		if err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}, nil
+}
 
-		header := md["authorization"]
+func StreamJwtClaimsInterceptor[T jwt.Claims](jwksUrls []string, claimsFunc func() T, requireAuthn bool) (grpc.StreamServerInterceptor, error) {
 
-		if len(header) < 1 {
-			if requireAuthn {
-				return nil, errMissingBearerToken
-			}
-			return handler(ctx, req)
+	Keyfunc, err := keyfunc.NewDefault(jwksUrls)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), Keyfunc, claimsFunc, requireAuthn)
+		if err != nil {
+			return err
 		}
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}, nil
+}
+
+func authenticate[T jwt.Claims](ctx context.Context, kf keyfunc.Keyfunc, claimsFunc func() T, requireAuthn bool) (context.Context, error) {
+	authCtx, err := verifyToken(ctx, kf, claimsFunc, requireAuthn)
+	if err != nil {
+		recordAuthFailure(ctx, err)
+	}
+	return authCtx, err
+}
 
-		claims := claimsFunc()
+func verifyToken[T jwt.Claims](ctx context.Context, kf keyfunc.Keyfunc, claimsFunc func() T, requireAuthn bool) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errMissingMetadata
+	}
 
-		signed := strings.TrimPrefix(header[0], "Bearer ")
-		token, err := jwt.ParseWithClaims(signed, claims, Keyfunc.Keyfunc)
-		if err != nil || !token.Valid {
-			return nil, errInvalidToken
+	header := md["authorization"]
+
+	if len(header) < 1 {
+		if requireAuthn {
+			return nil, errMissingBearerToken
 		}
+		return ctx, nil
+	}
 
-		span := trace.SpanFromContext(ctx)
+	claims := claimsFunc()
 
-		if sub, err := claims.GetSubject(); err != nil {
-			span.SetAttributes(attribute.String("user.id", sub))
-		}
-		ctx = context.WithValue(ctx, "claims", claims)
+	signed := strings.TrimPrefix(header[0], "Bearer ")
+	token, err := jwt.ParseWithClaims(signed, claims, kf.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
 
-		return handler(ctx, req)
-	}, nil
+	span := trace.SpanFromContext(ctx)
+
+	if sub, err := claims.GetSubject(); err == nil {
+		span.SetAttributes(attribute.String("user.id", sub))
+		accessLogEntryFromContext(ctx).setSubject(sub)
+	}
+
+	return context.WithValue(ctx, claimsKey{}, claims), nil
 }
 
 func GetClaimsFromContext[T jwt.Claims](ctx context.Context) (claims T, err error) {
 	var ok bool
-	claims, ok = ctx.Value("claims").(T)
+	claims, ok = ctx.Value(claimsKey{}).(T)
 	if !ok {
 		err = errors.New("no user in context")
 	}
@@ -1,44 +1,217 @@
 package boilerplate
 
 import (
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
+// WithConfig replaces the config set so far. A config returned by ParseConfig
+// is reloaded from the same file, environment and flags.
 func (s *boilerplate) WithConfig(conf BoilerplateConfig) *boilerplate {
 	s.config = conf
+	s.source = conf.source
+	s.overrides = nil
 	return s
 }
 
-func (s *boilerplate) WithServiceName(name string) *boilerplate {
-	s.config.ServiceName = name
+// WithConfigFile loads the file layered over the defaults and BOILERPLATE_*
+// environment variables. Errors are returned by Run. With reload enabled the
+// file is watched for changes.
+func (s *boilerplate) WithConfigFile(path string) *boilerplate {
+	s.source = &configSource{path: path}
+	s.config, s.configErr = s.source.load()
+	s.overrides = nil
 	return s
 }
 
-func (s *boilerplate) WithGrpcAddr(addr string) *boilerplate {
-	// TODO: validate host format
-	s.config.Grpc.Addr = addr
+// set applies a builder setting and keeps it, so it is applied over a
+// reloaded config again
+func (s *boilerplate) set(f func(*BoilerplateConfig)) *boilerplate {
+	f(&s.config)
+	s.overrides = append(s.overrides, f)
 	return s
 }
 
-func (s *boilerplate) WithGatewayAddr(addr string) *boilerplate {
-	s.config.Gateway.Addr = addr
+func (s *boilerplate) WithConfigReload() *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Reload.Enabled = true
+	})
+}
+
+func (s *boilerplate) OnConfigChange(f ConfigSubscriber) *boilerplate {
+	s.subscribe(f)
 	return s
 }
 
+func (s *boilerplate) WithServiceName(name string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.ServiceName = name
+	})
+}
+
+func (s *boilerplate) WithGrpcAddr(addr string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.Addr = addr
+	})
+}
+
+func (s *boilerplate) WithGatewayAddr(addr string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.Addr = addr
+	})
+}
+
+func (s *boilerplate) WithAdminAddr(addr string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Admin.Enabled = true
+		c.Admin.Addr = addr
+	})
+}
+
 func (s *boilerplate) WithTracer(name string) *boilerplate {
-	s.config.Otel.Enabled = true
-	s.config.Otel.Tracing.Enabled = true
-	s.config.Otel.TracerName = name
-	return s
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Enabled = true
+		c.Otel.Tracing.Enabled = true
+		c.Otel.TracerName = name
+	})
 }
 
 func (s *boilerplate) WithLogger(name string) *boilerplate {
-	s.config.Otel.Enabled = true
-	s.config.Otel.Logging.Enabled = true
-	s.config.Otel.LoggerName = name
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Enabled = true
+		c.Otel.Logging.Enabled = true
+		c.Otel.LoggerName = name
+	})
+}
+
+// WithSlog makes boilerplate log to l instead of slog.Default()
+func (s *boilerplate) WithSlog(l *slog.Logger) *boilerplate {
+	s.logBackend = slogBackend{log: l}
+	return s
+}
+
+// WithLogrus makes boilerplate log to l. With otel logging enabled, the log
+// bridge is added to l as hook.
+func (s *boilerplate) WithLogrus(l *logrus.Logger) *boilerplate {
+	s.logBackend = logrusBackend{log: l}
+	return s
+}
+
+// WithZap makes boilerplate log to l. With otel logging enabled, Logger()
+// returns a logger whose entries are bridged to otel as well.
+func (s *boilerplate) WithZap(l *zap.Logger) *boilerplate {
+	s.logBackend = zapBackend{log: l}
+	return s
+}
+
+func (s *boilerplate) WithLogLevel(level string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Log.Level = level
+	})
+}
+
+func (s *boilerplate) WithLogFormat(format string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Log.Format = format
+	})
+}
+
+// WithAccessLog logs every grpc call and gateway request, except for the
+// excluded methods and paths
+func (s *boilerplate) WithAccessLog(exclude ...string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Log.Access.Enabled = true
+		c.Log.Access.Exclude = append(c.Log.Access.Exclude, exclude...)
+	})
+}
+
+// WithRateLimit limits the calls to the methods matching the rules
+func (s *boilerplate) WithRateLimit(rules ...RateLimitRule) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.RateLimit.Enabled = true
+		c.RateLimit.Rules = append(c.RateLimit.Rules, rules...)
+	})
+}
+
+// WithRateLimitStore replaces the in memory token buckets, e.g. with a store
+// shared by all instances of the service
+func (s *boilerplate) WithRateLimitStore(store RateLimitStore) *boilerplate {
+	s.rateLimitStore = store
 	return s
 }
 
+// WithPrometheus serves metrics for scraping on path instead of pushing them
+func (s *boilerplate) WithPrometheus(path string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Enabled = true
+		c.Otel.Metrics.Enabled = true
+		c.Otel.Metrics.Protocol = "prometheus"
+		c.Otel.Prometheus.Path = path
+	})
+}
+
+func (s *boilerplate) WithServiceVersion(version string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Resource.ServiceVersion = version
+	})
+}
+
+func (s *boilerplate) WithServiceNamespace(namespace string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Resource.ServiceNamespace = namespace
+	})
+}
+
+func (s *boilerplate) WithServiceInstanceID(id string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Resource.ServiceInstanceID = id
+	})
+}
+
+func (s *boilerplate) WithDeploymentEnvironment(env string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Resource.DeploymentEnvironment = env
+	})
+}
+
+func (s *boilerplate) WithResourceAttribute(key string, value string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		if c.Otel.Resource.Attributes == nil {
+			c.Otel.Resource.Attributes = map[string]string{}
+		}
+		c.Otel.Resource.Attributes[key] = value
+	})
+}
+
+func (s *boilerplate) WithResourceDetectors(detectors ...string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Resource.Detectors = detectors
+	})
+}
+
+// WithSampler replaces the sampler config. A zero Ratio keeps the default of
+// 1, always_off drops all traces.
+func (s *boilerplate) WithSampler(sampler SamplerConfig) *boilerplate {
+	if sampler.Ratio == 0 {
+		sampler.Ratio = DEFAULT_SAMPLER_RATIO
+	}
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Sampler = sampler
+	})
+}
+
+func (s *boilerplate) WithSampleRatio(ratio float64) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Sampler.Type = "parentbased_traceidratio"
+		c.Otel.Sampler.Ratio = ratio
+	})
+}
+
 func (s *boilerplate) WithGrpcRegisterFunc(f GrpcRegisterFunc) *boilerplate {
 	s.grpcRegisterFunc = f
 	return s
@@ -50,13 +223,15 @@ func (s *boilerplate) WithGatewayRegisterFunc(f GatewayRegisterFunc) *boilerplat
 }
 
 func (s *boilerplate) WithOtlpProtocol(protocol string) *boilerplate {
-	s.config.Otel.Protocol = protocol
-	return s
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Protocol = protocol
+	})
 }
 
 func (s *boilerplate) WithOtlpInsecure() *boilerplate {
-	s.config.Otel.Insecure = true
-	return s
+	return s.set(func(c *BoilerplateConfig) {
+		c.Otel.Insecure = true
+	})
 }
 
 func (s *boilerplate) AddInterceptor(i grpc.UnaryServerInterceptor) *boilerplate {
@@ -64,17 +239,133 @@ func (s *boilerplate) AddInterceptor(i grpc.UnaryServerInterceptor) *boilerplate
 	return s
 }
 
-func (s *boilerplate) WithAllowedOrigins(origins []string) *boilerplate {
-	s.config.Gateway.AllowedOrigins = origins
+func (s *boilerplate) AddStreamInterceptor(i grpc.StreamServerInterceptor) *boilerplate {
+	s.streamInterceptors = append(s.streamInterceptors, i)
 	return s
 }
 
+func (s *boilerplate) WithReflection() *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.Reflection.Enabled = true
+	})
+}
+
+func (s *boilerplate) WithReflectionAuth(audience string, jwksUrls []string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.Reflection.Enabled = true
+		c.Grpc.Reflection.Audience = audience
+		c.Grpc.Reflection.JwksUrls = jwksUrls
+	})
+}
+
+func (s *boilerplate) WithGrpcKeepalive(conf KeepaliveConfig) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.Keepalive = conf
+	})
+}
+
+func (s *boilerplate) WithGrpcMaxMsgSize(recv int, send int) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.MaxRecvMsgSize = recv
+		c.Grpc.MaxSendMsgSize = send
+	})
+}
+
+func (s *boilerplate) WithGrpcMaxConcurrentStreams(n uint32) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.MaxConcurrentStreams = n
+	})
+}
+
+func (s *boilerplate) WithGrpcConnectionTimeout(timeout time.Duration) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.ConnectionTimeout = timeout
+	})
+}
+
+func (s *boilerplate) WithGrpcWindowSize(stream int32, conn int32) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.InitialWindowSize = stream
+		c.Grpc.InitialConnWindowSize = conn
+	})
+}
+
+func (s *boilerplate) WithGrpcMaxHeaderListSize(size uint32) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Grpc.MaxHeaderListSize = size
+	})
+}
+
+func (s *boilerplate) WithGatewayTimeouts(read, readHeader, write, idle time.Duration) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.ReadTimeout = read
+		c.Gateway.ReadHeaderTimeout = readHeader
+		c.Gateway.WriteTimeout = write
+		c.Gateway.IdleTimeout = idle
+	})
+}
+
+func (s *boilerplate) WithGatewayMaxHeaderBytes(n int) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.MaxHeaderBytes = n
+	})
+}
+
+func (s *boilerplate) WithGatewayRequestTimeout(timeout time.Duration) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.RequestTimeout = timeout
+	})
+}
+
+func (s *boilerplate) WithAllowedOrigins(origins []string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.AllowedOrigins = origins
+	})
+}
+
 func (s *boilerplate) WithAllowedMethod(methods []string) *boilerplate {
-	s.config.Gateway.AllowedMethods = methods
-	return s
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.AllowedMethods = methods
+	})
 }
 
 func (s *boilerplate) WithAllowedHeaders(headers []string) *boilerplate {
-	s.config.Gateway.AllowedHeaders = headers
-	return s
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.AllowedHeaders = headers
+	})
+}
+
+func (s *boilerplate) WithProblemDetails() *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.Problem.Enabled = true
+		c.Gateway.Problem.Details = true
+		c.Gateway.Problem.TraceID = true
+	})
+}
+
+func (s *boilerplate) AddOpenAPISpec(fsys fs.FS, paths ...string) *boilerplate {
+	for _, path := range paths {
+		s.openapiSpecs = append(s.openapiSpecs, openapiSpec{fsys: fsys, path: path})
+	}
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.OpenAPI.Enabled = true
+	})
+}
+
+func (s *boilerplate) WithOpenAPIUI(ui string) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.OpenAPI.UI = ui
+	})
+}
+
+func (s *boilerplate) WithOpenAPICDN() *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.OpenAPI.CDN = true
+	})
+}
+
+func (s *boilerplate) WithOpenAPIAssets(fsys fs.FS) *boilerplate {
+	return s.set(func(c *BoilerplateConfig) {
+		c.Gateway.OpenAPI.assets = fsys
+	})
 }
@@ -0,0 +1,469 @@
+package boilerplate
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+// overflowAttributes replace the attributes of measurements beyond the
+// cardinality limit, as the sdk does for its global limit
+var overflowAttributes = metric.WithAttributeSet(attribute.NewSet(attribute.Bool("otel.metric.overflow", true)))
+
+// cardinalityLimit admits up to limit-1 attribute sets, the last stream is
+// kept for the overflow. Sets stay admitted, as for cumulative temporality.
+type cardinalityLimit struct {
+	limit  int
+	filter attribute.Filter
+	mu     sync.RWMutex
+	seen   map[attribute.Distinct]struct{}
+}
+
+func newCardinalityLimit(limit int, filter attribute.Filter) *cardinalityLimit {
+	return &cardinalityLimit{limit: limit, filter: filter, seen: map[attribute.Distinct]struct{}{}}
+}
+
+// admit reports whether set is recorded as is. The set is counted as the
+// view's attribute filter leaves it.
+func (l *cardinalityLimit) admit(set attribute.Set) bool {
+	if l.filter != nil {
+		set, _ = set.Filter(l.filter)
+	}
+	key := set.Equivalent()
+
+	l.mu.RLock()
+	_, ok := l.seen[key]
+	l.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[key]; ok {
+		return true
+	}
+	if len(l.seen) >= l.limit-1 {
+		return false
+	}
+	l.seen[key] = struct{}{}
+	return true
+}
+
+func (l *cardinalityLimit) addOptions(opts []metric.AddOption) []metric.AddOption {
+	if l.admit(metric.NewAddConfig(opts).Attributes()) {
+		return opts
+	}
+	return []metric.AddOption{overflowAttributes}
+}
+
+func (l *cardinalityLimit) recordOptions(opts []metric.RecordOption) []metric.RecordOption {
+	if l.admit(metric.NewRecordConfig(opts).Attributes()) {
+		return opts
+	}
+	return []metric.RecordOption{overflowAttributes}
+}
+
+func (l *cardinalityLimit) observeOptions(opts []metric.ObserveOption) []metric.ObserveOption {
+	if l.admit(metric.NewObserveConfig(opts).Attributes()) {
+		return opts
+	}
+	return []metric.ObserveOption{overflowAttributes}
+}
+
+// cardinalityMeterProvider applies the cardinality limits of the views to
+// the instruments they match. The sdk only supports a global limit
+// (OTEL_GO_X_CARDINALITY_LIMIT).
+type cardinalityMeterProvider struct {
+	embedded.MeterProvider
+	next  metric.MeterProvider
+	views []ViewConfig
+}
+
+// newCardinalityMeterProvider returns mp unchanged if no view sets a limit
+func newCardinalityMeterProvider(mp metric.MeterProvider, views []ViewConfig) metric.MeterProvider {
+	for _, view := range views {
+		if view.CardinalityLimit > 0 {
+			return &cardinalityMeterProvider{next: mp, views: views}
+		}
+	}
+	return mp
+}
+
+func (p *cardinalityMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return &cardinalityMeter{Meter: p.next.Meter(name, opts...), name: name, views: p.views}
+}
+
+type cardinalityMeter struct {
+	metric.Meter
+	name  string
+	views []ViewConfig
+}
+
+// limit returns the limit of the first view with a limit matching the
+// instrument. Attribute sets are counted as the filter of the view newViews
+// applies to the instrument leaves them.
+func (m *cardinalityMeter) limit(instrument string) *cardinalityLimit {
+	var limit int
+	var filter attribute.Filter
+	filtered := false
+
+	for _, view := range m.views {
+		if !view.matches(m.name, instrument) {
+			continue
+		}
+		if limit == 0 && view.CardinalityLimit > 0 {
+			limit = view.CardinalityLimit
+		}
+		if !filtered && view.changesStream() {
+			filter, filtered = attributeFilter(view.AllowAttributes, view.DenyAttributes), true
+		}
+	}
+
+	if limit == 0 {
+		return nil
+	}
+	return newCardinalityLimit(limit, filter)
+}
+
+func (m *cardinalityMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	i, err := m.Meter.Int64Counter(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return int64Counter{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Int64UpDownCounter(name string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	i, err := m.Meter.Int64UpDownCounter(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return int64UpDownCounter{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Int64Histogram(name string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	i, err := m.Meter.Int64Histogram(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return int64Histogram{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Int64Gauge(name string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	i, err := m.Meter.Int64Gauge(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return int64Gauge{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Float64Counter(name string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	i, err := m.Meter.Float64Counter(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return float64Counter{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Float64UpDownCounter(name string, options ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
+	i, err := m.Meter.Float64UpDownCounter(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return float64UpDownCounter{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Float64Histogram(name string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	i, err := m.Meter.Float64Histogram(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return float64Histogram{i, l}, nil
+	}
+	return i, err
+}
+
+func (m *cardinalityMeter) Float64Gauge(name string, options ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	i, err := m.Meter.Float64Gauge(name, options...)
+	if l := m.limit(name); l != nil && err == nil {
+		return float64Gauge{i, l}, nil
+	}
+	return i, err
+}
+
+// the callbacks of observable instruments get an observer applying the limit
+
+func (m *cardinalityMeter) Int64ObservableCounter(name string, options ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Int64ObservableCounter(name, options...)
+	}
+	conf := metric.NewInt64ObservableCounterConfig(options...)
+	i, err := m.Meter.Int64ObservableCounter(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), int64Callbacks(conf.Callbacks(), l))
+	return int64ObservableCounter{i, l}, err
+}
+
+func (m *cardinalityMeter) Int64ObservableUpDownCounter(name string, options ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Int64ObservableUpDownCounter(name, options...)
+	}
+	conf := metric.NewInt64ObservableUpDownCounterConfig(options...)
+	i, err := m.Meter.Int64ObservableUpDownCounter(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), int64Callbacks(conf.Callbacks(), l))
+	return int64ObservableUpDownCounter{i, l}, err
+}
+
+func (m *cardinalityMeter) Int64ObservableGauge(name string, options ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Int64ObservableGauge(name, options...)
+	}
+	conf := metric.NewInt64ObservableGaugeConfig(options...)
+	i, err := m.Meter.Int64ObservableGauge(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), int64Callbacks(conf.Callbacks(), l))
+	return int64ObservableGauge{i, l}, err
+}
+
+func (m *cardinalityMeter) Float64ObservableCounter(name string, options ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Float64ObservableCounter(name, options...)
+	}
+	conf := metric.NewFloat64ObservableCounterConfig(options...)
+	i, err := m.Meter.Float64ObservableCounter(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), float64Callbacks(conf.Callbacks(), l))
+	return float64ObservableCounter{i, l}, err
+}
+
+func (m *cardinalityMeter) Float64ObservableUpDownCounter(name string, options ...metric.Float64ObservableUpDownCounterOption) (metric.Float64ObservableUpDownCounter, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Float64ObservableUpDownCounter(name, options...)
+	}
+	conf := metric.NewFloat64ObservableUpDownCounterConfig(options...)
+	i, err := m.Meter.Float64ObservableUpDownCounter(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), float64Callbacks(conf.Callbacks(), l))
+	return float64ObservableUpDownCounter{i, l}, err
+}
+
+func (m *cardinalityMeter) Float64ObservableGauge(name string, options ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	l := m.limit(name)
+	if l == nil {
+		return m.Meter.Float64ObservableGauge(name, options...)
+	}
+	conf := metric.NewFloat64ObservableGaugeConfig(options...)
+	i, err := m.Meter.Float64ObservableGauge(name, metric.WithDescription(conf.Description()), metric.WithUnit(conf.Unit()), float64Callbacks(conf.Callbacks(), l))
+	return float64ObservableGauge{i, l}, err
+}
+
+// RegisterCallback hands the sdk its own instruments and the callback an
+// observer applying the limits of the wrapped ones
+func (m *cardinalityMeter) RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
+	unwrapped := make([]metric.Observable, len(instruments))
+	for i, instrument := range instruments {
+		unwrapped[i] = unwrapObservable(instrument)
+	}
+	return m.Meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		return f(ctx, limitedObserver{o})
+	}, unwrapped...)
+}
+
+type limitedObservable interface {
+	unwrap() (metric.Observable, *cardinalityLimit)
+}
+
+func unwrapObservable(o metric.Observable) metric.Observable {
+	if w, ok := o.(limitedObservable); ok {
+		o, _ = w.unwrap()
+	}
+	return o
+}
+
+type limitedObserver struct {
+	metric.Observer
+}
+
+func (o limitedObserver) ObserveInt64(obsrv metric.Int64Observable, value int64, opts ...metric.ObserveOption) {
+	if w, ok := obsrv.(limitedObservable); ok {
+		inner, l := w.unwrap()
+		o.Observer.ObserveInt64(inner.(metric.Int64Observable), value, l.observeOptions(opts)...)
+		return
+	}
+	o.Observer.ObserveInt64(obsrv, value, opts...)
+}
+
+func (o limitedObserver) ObserveFloat64(obsrv metric.Float64Observable, value float64, opts ...metric.ObserveOption) {
+	if w, ok := obsrv.(limitedObservable); ok {
+		inner, l := w.unwrap()
+		o.Observer.ObserveFloat64(inner.(metric.Float64Observable), value, l.observeOptions(opts)...)
+		return
+	}
+	o.Observer.ObserveFloat64(obsrv, value, opts...)
+}
+
+func int64Callbacks(callbacks []metric.Int64Callback, l *cardinalityLimit) metric.Int64ObservableOption {
+	return metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+		observer := int64Observer{o, l}
+		for _, callback := range callbacks {
+			if err := callback(ctx, observer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func float64Callbacks(callbacks []metric.Float64Callback, l *cardinalityLimit) metric.Float64ObservableOption {
+	return metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+		observer := float64Observer{o, l}
+		for _, callback := range callbacks {
+			if err := callback(ctx, observer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type int64Observer struct {
+	metric.Int64Observer
+	limit *cardinalityLimit
+}
+
+func (o int64Observer) Observe(value int64, opts ...metric.ObserveOption) {
+	o.Int64Observer.Observe(value, o.limit.observeOptions(opts)...)
+}
+
+type float64Observer struct {
+	metric.Float64Observer
+	limit *cardinalityLimit
+}
+
+func (o float64Observer) Observe(value float64, opts ...metric.ObserveOption) {
+	o.Float64Observer.Observe(value, o.limit.observeOptions(opts)...)
+}
+
+type int64Counter struct {
+	metric.Int64Counter
+	limit *cardinalityLimit
+}
+
+func (i int64Counter) Add(ctx context.Context, value int64, opts ...metric.AddOption) {
+	i.Int64Counter.Add(ctx, value, i.limit.addOptions(opts)...)
+}
+
+type int64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	limit *cardinalityLimit
+}
+
+func (i int64UpDownCounter) Add(ctx context.Context, value int64, opts ...metric.AddOption) {
+	i.Int64UpDownCounter.Add(ctx, value, i.limit.addOptions(opts)...)
+}
+
+type int64Histogram struct {
+	metric.Int64Histogram
+	limit *cardinalityLimit
+}
+
+func (i int64Histogram) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	i.Int64Histogram.Record(ctx, value, i.limit.recordOptions(opts)...)
+}
+
+type int64Gauge struct {
+	metric.Int64Gauge
+	limit *cardinalityLimit
+}
+
+func (i int64Gauge) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	i.Int64Gauge.Record(ctx, value, i.limit.recordOptions(opts)...)
+}
+
+type float64Counter struct {
+	metric.Float64Counter
+	limit *cardinalityLimit
+}
+
+func (i float64Counter) Add(ctx context.Context, value float64, opts ...metric.AddOption) {
+	i.Float64Counter.Add(ctx, value, i.limit.addOptions(opts)...)
+}
+
+type float64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	limit *cardinalityLimit
+}
+
+func (i float64UpDownCounter) Add(ctx context.Context, value float64, opts ...metric.AddOption) {
+	i.Float64UpDownCounter.Add(ctx, value, i.limit.addOptions(opts)...)
+}
+
+type float64Histogram struct {
+	metric.Float64Histogram
+	limit *cardinalityLimit
+}
+
+func (i float64Histogram) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	i.Float64Histogram.Record(ctx, value, i.limit.recordOptions(opts)...)
+}
+
+type float64Gauge struct {
+	metric.Float64Gauge
+	limit *cardinalityLimit
+}
+
+func (i float64Gauge) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	i.Float64Gauge.Record(ctx, value, i.limit.recordOptions(opts)...)
+}
+
+type int64ObservableCounter struct {
+	metric.Int64ObservableCounter
+	limit *cardinalityLimit
+}
+
+func (i int64ObservableCounter) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Int64ObservableCounter, i.limit
+}
+
+type int64ObservableUpDownCounter struct {
+	metric.Int64ObservableUpDownCounter
+	limit *cardinalityLimit
+}
+
+func (i int64ObservableUpDownCounter) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Int64ObservableUpDownCounter, i.limit
+}
+
+type int64ObservableGauge struct {
+	metric.Int64ObservableGauge
+	limit *cardinalityLimit
+}
+
+func (i int64ObservableGauge) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Int64ObservableGauge, i.limit
+}
+
+type float64ObservableCounter struct {
+	metric.Float64ObservableCounter
+	limit *cardinalityLimit
+}
+
+func (i float64ObservableCounter) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Float64ObservableCounter, i.limit
+}
+
+type float64ObservableUpDownCounter struct {
+	metric.Float64ObservableUpDownCounter
+	limit *cardinalityLimit
+}
+
+func (i float64ObservableUpDownCounter) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Float64ObservableUpDownCounter, i.limit
+}
+
+type float64ObservableGauge struct {
+	metric.Float64ObservableGauge
+	limit *cardinalityLimit
+}
+
+func (i float64ObservableGauge) unwrap() (metric.Observable, *cardinalityLimit) {
+	return i.Float64ObservableGauge, i.limit
+}
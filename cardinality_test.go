@@ -0,0 +1,252 @@
+package boilerplate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectMetrics sets up the meter provider as setupOtel does, records with
+// the meter "test" and returns the collected metrics by name
+func collectMetrics(t *testing.T, conf OtelConfig, record func(t *testing.T, m metric.Meter)) map[string]metricdata.Aggregation {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	sdk := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(newViews(conf)...))
+	t.Cleanup(func() { sdk.Shutdown(context.Background()) })
+
+	record(t, newCardinalityMeterProvider(sdk, conf.Views).Meter("test"))
+	return collectReader(t, reader)
+}
+
+// collectReader returns the metrics collected by reader by name
+func collectReader(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+// dataPoints returns the values of a sum or gauge, or the counts of a
+// histogram, by encoded attribute set
+func dataPoints(t *testing.T, data metricdata.Aggregation) map[string]int64 {
+	t.Helper()
+	points := map[string]int64{}
+	switch data := data.(type) {
+	case metricdata.Sum[int64]:
+		for _, p := range data.DataPoints {
+			points[p.Attributes.Encoded(attribute.DefaultEncoder())] = p.Value
+		}
+	case metricdata.Gauge[int64]:
+		for _, p := range data.DataPoints {
+			points[p.Attributes.Encoded(attribute.DefaultEncoder())] = p.Value
+		}
+	case metricdata.Histogram[float64]:
+		for _, p := range data.DataPoints {
+			points[p.Attributes.Encoded(attribute.DefaultEncoder())] = int64(p.Count)
+		}
+	default:
+		t.Fatalf("unexpected aggregation %T", data)
+	}
+	return points
+}
+
+func userAttributes(users ...string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, len(users))
+	for i, user := range users {
+		attrs[i] = attribute.String("user", user)
+	}
+	return attrs
+}
+
+func addUsers(users ...string) func(t *testing.T, m metric.Meter) {
+	return func(t *testing.T, m metric.Meter) {
+		counter, err := m.Int64Counter("requests")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, attr := range userAttributes(users...) {
+			counter.Add(context.Background(), 1, metric.WithAttributes(attr))
+		}
+	}
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	const overflow = "otel.metric.overflow=true"
+
+	tests := []struct {
+		name   string
+		views  []ViewConfig
+		record func(t *testing.T, m metric.Meter)
+		want   map[string]int64
+	}{
+		{
+			name:   "overflow",
+			views:  []ViewConfig{{Instrument: "requests", CardinalityLimit: 3}},
+			record: addUsers("a", "b", "c", "d"),
+			want:   map[string]int64{"user=a": 1, "user=b": 1, overflow: 2},
+		},
+		{
+			name:   "sets recorded before the limit are reused",
+			views:  []ViewConfig{{Instrument: "requests", CardinalityLimit: 3}},
+			record: addUsers("a", "b", "c", "a", "b", "d"),
+			want:   map[string]int64{"user=a": 2, "user=b": 2, overflow: 2},
+		},
+		{
+			name:   "no limit",
+			views:  []ViewConfig{{Instrument: "other", CardinalityLimit: 2}},
+			record: addUsers("a", "b", "c"),
+			want:   map[string]int64{"user=a": 1, "user=b": 1, "user=c": 1},
+		},
+		{
+			name:   "meter only view",
+			views:  []ViewConfig{{Meter: "test", CardinalityLimit: 2}},
+			record: addUsers("a", "b", "c"),
+			want:   map[string]int64{"user=a": 1, overflow: 2},
+		},
+		{
+			name:   "view of another meter",
+			views:  []ViewConfig{{Meter: "other", CardinalityLimit: 2}},
+			record: addUsers("a", "b"),
+			want:   map[string]int64{"user=a": 1, "user=b": 1},
+		},
+		{
+			name:   "first view with a limit applies",
+			views:  []ViewConfig{{Instrument: "requests", Description: "requests"}, {Instrument: "req*", CardinalityLimit: 2}, {Instrument: "*", CardinalityLimit: 10}},
+			record: addUsers("a", "b"),
+			want:   map[string]int64{"user=a": 1, overflow: 1},
+		},
+		{
+			name: "sets are counted after the attribute filter",
+			views: []ViewConfig{
+				{Instrument: "requests", AllowAttributes: []string{"route"}},
+				{Instrument: "*", CardinalityLimit: 3},
+			},
+			record: func(t *testing.T, m metric.Meter) {
+				counter, err := m.Int64Counter("requests")
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, route := range []string{"/a", "/a", "/b", "/c"} {
+					counter.Add(context.Background(), 1, metric.WithAttributes(
+						attribute.String("route", route), attribute.Int("user", i)))
+				}
+			},
+			want: map[string]int64{"route=/a": 2, "route=/b": 1, overflow: 1},
+		},
+		{
+			name:  "observable instruments",
+			views: []ViewConfig{{Instrument: "sessions", CardinalityLimit: 3}},
+			record: func(t *testing.T, m metric.Meter) {
+				_, err := m.Int64ObservableGauge("sessions", metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+					for _, attr := range userAttributes("a", "b", "c") {
+						o.Observe(1, metric.WithAttributes(attr))
+					}
+					return nil
+				}))
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]int64{"user=a": 1, "user=b": 1, overflow: 1},
+		},
+		{
+			name:  "observable instruments with registered callbacks",
+			views: []ViewConfig{{Instrument: "sessions", CardinalityLimit: 2}},
+			record: func(t *testing.T, m metric.Meter) {
+				gauge, err := m.Int64ObservableGauge("sessions")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = m.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+					for _, attr := range userAttributes("a", "b") {
+						o.ObserveInt64(gauge, 1, metric.WithAttributes(attr))
+					}
+					return nil
+				}, gauge)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]int64{"user=a": 1, overflow: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := collectMetrics(t, OtelConfig{Views: tt.views}, tt.record)
+			if len(metrics) != 1 {
+				t.Fatalf("got %d metrics, want 1", len(metrics))
+			}
+			for _, data := range metrics {
+				if got := dataPoints(t, data); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestViewsWithCardinalityLimit(t *testing.T) {
+	conf := OtelConfig{
+		Views: []ViewConfig{
+			{Instrument: "*", CardinalityLimit: 2},
+			{Instrument: "requests", Rename: "calls"},
+		},
+		Instrumentation: InstrumentationConfig{
+			HistogramBuckets: map[string][]float64{"latency": {1, 2}},
+		},
+	}
+
+	metrics := collectMetrics(t, conf, func(t *testing.T, m metric.Meter) {
+		addUsers("a", "b")(t, m)
+		histogram, err := m.Float64Histogram("latency")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, attr := range userAttributes("a", "b") {
+			histogram.Record(context.Background(), 1.5, metric.WithAttributes(attr))
+		}
+	})
+
+	tests := []struct {
+		name   string
+		metric string
+		want   map[string]int64
+	}{
+		{"rename after a limit only view", "calls", map[string]int64{"user=a": 1, "otel.metric.overflow=true": 1}},
+		{"histogram buckets after a limit only view", "latency", map[string]int64{"user=a": 1, "otel.metric.overflow=true": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ok := metrics[tt.metric]
+			if !ok {
+				t.Fatalf("no metric %q", tt.metric)
+			}
+			if got := dataPoints(t, data); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	histogram := metrics["latency"].(metricdata.Histogram[float64])
+	for _, p := range histogram.DataPoints {
+		if !slices.Equal(p.Bounds, []float64{1, 2}) {
+			t.Errorf("got bounds %v, want the histogramBuckets [1 2]", p.Bounds)
+		}
+	}
+}
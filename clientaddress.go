@@ -0,0 +1,100 @@
+package boilerplate
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// the gateway passes the address of its client to the grpc server under
+// private keys, along with a token only the gateway of this process knows.
+// Clients can't set them, the gateway drops them from incoming headers and
+// the grpc server drops them from the metadata after checking the token.
+const (
+	clientAddressHeader = "x-boilerplate-client-address"
+	gatewayTokenHeader  = "x-boilerplate-gateway-token"
+)
+
+type clientAddressKey struct{}
+
+func newGatewayToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func privateHeader(key string) bool {
+	return key == clientAddressHeader || key == gatewayTokenHeader
+}
+
+// gatewayMetadata passes the address of the gateway's client to the grpc
+// server
+func gatewayMetadata(token string) func(context.Context, *http.Request) metadata.MD {
+	return func(ctx context.Context, r *http.Request) metadata.MD {
+		return metadata.Pairs(clientAddressHeader, r.RemoteAddr, gatewayTokenHeader, token)
+	}
+}
+
+// trustClientAddress keeps the client address passed by the gateway if the
+// call carries its token
+func trustClientAddress(ctx context.Context, token string) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tokens, addrs := md.Get(gatewayTokenHeader), md.Get(clientAddressHeader)
+	if len(tokens) == 0 && len(addrs) == 0 {
+		return ctx
+	}
+
+	md = md.Copy()
+	md.Delete(gatewayTokenHeader)
+	md.Delete(clientAddressHeader)
+	ctx = metadata.NewIncomingContext(ctx, md)
+
+	if token == "" || len(tokens) != 1 || len(addrs) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(token)) != 1 {
+		return ctx
+	}
+	return context.WithValue(ctx, clientAddressKey{}, addrs[0])
+}
+
+func clientAddressUnaryInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(trustClientAddress(ctx, token), req)
+	}
+}
+
+func clientAddressStreamInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: trustClientAddress(ss.Context(), token)})
+	}
+}
+
+// clientAddress returns the address of the client, the gateway's client for
+// calls through the gateway
+func clientAddress(ctx context.Context) string {
+	if addr, ok := ctx.Value(clientAddressKey{}).(string); ok {
+		return addr
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// clientIP returns the host of clientAddress
+func clientIP(ctx context.Context) string {
+	addr := clientAddress(ctx)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
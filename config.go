@@ -1,27 +1,77 @@
 package boilerplate
 
 import (
+	"cmp"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"strings"
 	"time"
 )
 
 const (
 	DEFAULT_GRPC_ADDR     = ":50001"
 	DEFAULT_GATEWAY_ADDR  = ":50002"
+	DEFAULT_ADMIN_ADDR    = ":50003"
+	DEFAULT_SERVICE_NAME  = "UnnamedBoilerplateService"
 	DEFAULT_OTEL_ADDR     = "127.0.0.1:4317"
-	DEFAULT_OTEL_INTERVAL = 5
+	DEFAULT_OTEL_PROTOCOL = "grpc"
+	DEFAULT_OTEL_INTERVAL = 5 * time.Second
+
+	DEFAULT_OTEL_RETRY_INITIAL_INTERVAL = 5 * time.Second
+	DEFAULT_OTEL_RETRY_MAX_INTERVAL     = 30 * time.Second
+	DEFAULT_OTEL_RETRY_MAX_ELAPSED_TIME = time.Minute
+
+	DEFAULT_GRPC_MAX_RECV_MSG_SIZE        = 4 << 20
+	DEFAULT_GRPC_MAX_CONCURRENT_STREAMS   = 1000
+	DEFAULT_GRPC_CONNECTION_TIMEOUT       = 20 * time.Second
+	DEFAULT_GRPC_KEEPALIVE_MIN_TIME       = 10 * time.Second
+	DEFAULT_GRPC_MAX_CONNECTION_IDLE      = 15 * time.Minute
+	DEFAULT_GRPC_MAX_CONNECTION_AGE_GRACE = 30 * time.Second
+	DEFAULT_GRPC_KEEPALIVE_TIME           = 2 * time.Minute
+	DEFAULT_GRPC_KEEPALIVE_TIMEOUT        = 20 * time.Second
+
+	DEFAULT_GATEWAY_READ_TIMEOUT        = 30 * time.Second
+	DEFAULT_GATEWAY_READ_HEADER_TIMEOUT = 10 * time.Second
+	DEFAULT_GATEWAY_IDLE_TIMEOUT        = 120 * time.Second
+	DEFAULT_GATEWAY_MAX_HEADER_BYTES    = 1 << 20
+
+	DEFAULT_PROMETHEUS_PATH = "/metrics"
+
+	DEFAULT_LOG_LEVEL  = "info"
+	DEFAULT_LOG_FORMAT = "text"
+
+	DEFAULT_ACCESS_LOG_SUCCESS_RATIO = 1.0
+
+	DEFAULT_RATE_LIMIT_KEY            = "peer"
+	DEFAULT_RATE_LIMIT_API_KEY_HEADER = "x-api-key"
 )
 
 var defaultConfig = BoilerplateConfig{
-	ServiceName: "UnnamedBoilerplateService",
-	Grpc: ServerConfig{
-		Addr: DEFAULT_GRPC_ADDR,
-		TLS: TlsConfig{
-			Enabled: false,
-			Mutual:  true,
-			Cert:    "certs/server_cert.pem",
-			Key:     "certs/server_key.pem",
-			Ca:      "certs/client_ca_cert.pem",
+	Grpc: GrpcConfig{
+		ServerConfig: ServerConfig{
+			Addr: DEFAULT_GRPC_ADDR,
+			TLS: TlsConfig{
+				Enabled: false,
+				Mutual:  true,
+				Cert:    "certs/server_cert.pem",
+				Key:     "certs/server_key.pem",
+				Ca:      "certs/client_ca_cert.pem",
+			},
 		},
+		Keepalive: KeepaliveConfig{
+			MinTime:               DEFAULT_GRPC_KEEPALIVE_MIN_TIME,
+			PermitWithoutStream:   true,
+			MaxConnectionIdle:     DEFAULT_GRPC_MAX_CONNECTION_IDLE,
+			MaxConnectionAgeGrace: DEFAULT_GRPC_MAX_CONNECTION_AGE_GRACE,
+			Time:                  DEFAULT_GRPC_KEEPALIVE_TIME,
+			Timeout:               DEFAULT_GRPC_KEEPALIVE_TIMEOUT,
+		},
+		MaxRecvMsgSize:       DEFAULT_GRPC_MAX_RECV_MSG_SIZE,
+		MaxConcurrentStreams: DEFAULT_GRPC_MAX_CONCURRENT_STREAMS,
+		ConnectionTimeout:    DEFAULT_GRPC_CONNECTION_TIMEOUT,
 	},
 	Gateway: GatewayConfig{
 		ServerConfig: ServerConfig{
@@ -33,17 +83,23 @@ var defaultConfig = BoilerplateConfig{
 				Ca:     "certs/server_ca_cert.pem",
 			},
 		},
-		AllowedOrigins: []string{"*"},
+		AllowedOrigins:    []string{"*"},
+		ReadTimeout:       DEFAULT_GATEWAY_READ_TIMEOUT,
+		ReadHeaderTimeout: DEFAULT_GATEWAY_READ_HEADER_TIMEOUT,
+		IdleTimeout:       DEFAULT_GATEWAY_IDLE_TIMEOUT,
+		MaxHeaderBytes:    DEFAULT_GATEWAY_MAX_HEADER_BYTES,
+		OpenAPI: OpenAPIConfig{
+			CDN: true,
+		},
 	},
 
 	Otel: OtelConfig{
 		TracerName: "github.com/sekthor/boilerplate",
 		LoggerName: "github.com/sekthor/boilerplate",
+		// address, protocol and interval are left blank, so the OTEL_*
+		// environment variables can fill them in
 		OtelExporterConfig: OtelExporterConfig{
-			Enabled:  true,
-			Addr:     DEFAULT_OTEL_ADDR,
-			Protocol: "grpc",
-			Interval: 5,
+			Enabled: true,
 		},
 		Logging: OtelExporterConfig{
 			Enabled: true,
@@ -51,52 +107,400 @@ var defaultConfig = BoilerplateConfig{
 		Metrics: OtelExporterConfig{
 			Enabled: true,
 		},
+		Instrumentation: InstrumentationConfig{
+			Runtime:   true,
+			Host:      true,
+			BuildInfo: true,
+		},
+		Sampler: SamplerConfig{
+			Ratio: DEFAULT_SAMPLER_RATIO,
+		},
+	},
+
+	Admin: AdminConfig{
+		Addr: DEFAULT_ADMIN_ADDR,
+	},
+
+	Log: LogConfig{
+		Level:  DEFAULT_LOG_LEVEL,
+		Format: DEFAULT_LOG_FORMAT,
+		Access: AccessLogConfig{
+			SuccessRatio: DEFAULT_ACCESS_LOG_SUCCESS_RATIO,
+			Exclude:      []string{"/grpc.health.v1.Health/*"},
+		},
 	},
 }
 
 type BoilerplateConfig struct {
-	ServiceName string
-	Grpc        ServerConfig
-	Gateway     GatewayConfig
-	Otel        OtelConfig
+	ServiceName string          `yaml:"serviceName"`
+	Grpc        GrpcConfig      `yaml:"grpc"`
+	Gateway     GatewayConfig   `yaml:"gateway"`
+	Otel        OtelConfig      `yaml:"otel"`
+	Admin       AdminConfig     `yaml:"admin"`
+	Log         LogConfig       `yaml:"log"`
+	RateLimit   RateLimitConfig `yaml:"rateLimit"`
+	Reload      ReloadConfig    `yaml:"reload"`
+
+	// set by ParseConfig to rebuild the config on reload
+	source *configSource
+}
+
+// AdminConfig configures a plain http listener for operational endpoints,
+// like the prometheus metrics, separate from the public gateway
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+	// bearer token required to change the log level, without one the level
+	// is read only
+	Token string `yaml:"token" redact:"true"`
+}
+
+// LogConfig configures the logs boilerplate writes. Format and source only
+// apply to the logger boilerplate creates, a logger passed to WithSlog,
+// WithLogrus or WithZap keeps its own output settings.
+type LogConfig struct {
+	// debug, info, warn or error
+	Level string `yaml:"level"`
+	// text or json, logfmt is the same as text
+	Format string `yaml:"format"`
+	// add the file and line of the log call
+	Source bool            `yaml:"source"`
+	Access AccessLogConfig `yaml:"access"`
+}
+
+// AccessLogConfig controls the record logged per grpc call and gateway
+// request
+type AccessLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// share of successful requests that are logged, 0 logs none
+	SuccessRatio float64 `yaml:"successRatio"`
+	// grpc methods (/package.Service/Method), http paths and routes that are
+	// not logged, as path.Match patterns
+	Exclude []string `yaml:"exclude"`
+}
+
+func (c LogConfig) level() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cmp.Or(c.Level, DEFAULT_LOG_LEVEL))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// RateLimitConfig limits the grpc calls, including those made through the
+// gateway, per method and client
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// what identifies a client: subject (of the jwt), apiKey or peer. Calls
+	// without a subject or api key are limited by peer.
+	Key string `yaml:"key"`
+	// metadata or http header holding the api key
+	ApiKeyHeader string `yaml:"apiKeyHeader"`
+	// limit of the methods no rule matches, unset means unlimited
+	Default RateLimitRule   `yaml:"default"`
+	Rules   []RateLimitRule `yaml:"rules"`
+}
+
+func (c RateLimitConfig) apiKeyHeader() string {
+	return strings.ToLower(cmp.Or(c.ApiKeyHeader, DEFAULT_RATE_LIMIT_API_KEY_HEADER))
+}
+
+// RateLimitRule is a token bucket per client. Methods matching the same rule
+// share the bucket.
+type RateLimitRule struct {
+	// full method name as path.Match pattern, e.g. /greeter.v1.GreeterService/*
+	Method string `yaml:"method"`
+	// tokens added per second
+	Rate float64 `yaml:"rate"`
+	// bucket size, defaults to the rate rounded up
+	Burst int `yaml:"burst"`
+	// overrides the key of the rate limit config
+	Key string `yaml:"key"`
+}
+
+// ReloadConfig controls watching the file passed to WithConfigFile
+type ReloadConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// how often the file is checked for changes, SIGHUP always reloads
+	Interval time.Duration `yaml:"interval"`
+}
+
+// GrpcConfig holds the grpc server settings. Zero values leave the
+// respective grpc-go default in place.
+type GrpcConfig struct {
+	ServerConfig `yaml:",inline"`
+	Reflection   ReflectionConfig `yaml:"reflection"`
+	Keepalive    KeepaliveConfig  `yaml:"keepalive"`
+	// max message size the server can receive in bytes (grpc-go: 4MiB)
+	MaxRecvMsgSize int `yaml:"maxRecvMsgSize"`
+	// max message size the server can send in bytes (grpc-go: math.MaxInt32)
+	MaxSendMsgSize int `yaml:"maxSendMsgSize"`
+	// max concurrent streams per client connection (grpc-go: unlimited)
+	MaxConcurrentStreams uint32 `yaml:"maxConcurrentStreams"`
+	// timeout for the connection handshake (grpc-go: 120s)
+	ConnectionTimeout time.Duration `yaml:"connectionTimeout"`
+	// per stream flow control window, values below 64KiB are ignored
+	InitialWindowSize int32 `yaml:"initialWindowSize"`
+	// per connection flow control window, values below 64KiB are ignored
+	InitialConnWindowSize int32 `yaml:"initialConnWindowSize"`
+	// max size of the received header list in bytes (grpc-go: 16MiB)
+	MaxHeaderListSize uint32 `yaml:"maxHeaderListSize"`
+}
+
+type KeepaliveConfig struct {
+	// enforcement policy: minimum time a client must wait between pings
+	// (grpc-go: 5m)
+	MinTime time.Duration `yaml:"minTime"`
+	// enforcement policy: allow pings without active streams
+	PermitWithoutStream bool `yaml:"permitWithoutStream"`
+	// close connections idle for this long (grpc-go: infinity)
+	MaxConnectionIdle time.Duration `yaml:"maxConnectionIdle"`
+	// close connections after this age (grpc-go: infinity)
+	MaxConnectionAge time.Duration `yaml:"maxConnectionAge"`
+	// time to finish pending rpcs after MaxConnectionAge (grpc-go: infinity)
+	MaxConnectionAgeGrace time.Duration `yaml:"maxConnectionAgeGrace"`
+	// ping clients after this long without activity (grpc-go: 2h)
+	Time time.Duration `yaml:"time"`
+	// wait this long for a ping ack before closing (grpc-go: 20s)
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type ReflectionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// if set, reflection calls require a bearer token signed by one of these
+	JwksUrls []string `yaml:"jwksUrls"`
+	// audience the tokens must be issued for, required with jwksUrls
+	Audience string `yaml:"audience"`
+	// claims the tokens must carry, e.g. role: operator. List claims must
+	// contain the value.
+	Claims map[string]string `yaml:"claims"`
 }
 
 type GatewayConfig struct {
-	ServerConfig
-	AllowedOrigins []string
-	AllowedMethods []string
-	AllowedHeaders []string
+	ServerConfig   `yaml:",inline"`
+	AllowedOrigins []string      `yaml:"allowedOrigins"`
+	AllowedMethods []string      `yaml:"allowedMethods"`
+	AllowedHeaders []string      `yaml:"allowedHeaders"`
+	Problem        ProblemConfig `yaml:"problem"`
+	OpenAPI        OpenAPIConfig `yaml:"openapi"`
+
+	ReadTimeout time.Duration `yaml:"readTimeout"`
+	// always enforced, falls back to DEFAULT_GATEWAY_READ_HEADER_TIMEOUT
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	// unlimited by default, as it would cut off server streaming responses.
+	// requestTimeout bounds the other calls.
+	WriteTimeout   time.Duration `yaml:"writeTimeout"`
+	IdleTimeout    time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes int           `yaml:"maxHeaderBytes"`
+	// deadline for each request, propagated to the grpc call
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+}
+
+type ProblemConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	TypeBaseURL string `yaml:"typeBaseUrl"`
+	Details     bool   `yaml:"details"`
+	TraceID     bool   `yaml:"traceId"`
+}
+
+type OpenAPIConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path the merged spec is served at, defaults to /openapi.json
+	Path string `yaml:"path"`
+	// UI is either empty (no ui), "swagger" or "redoc"
+	UI     string `yaml:"ui"`
+	UIPath string `yaml:"uiPath"`
+	// CDN loads the ui bundle from unpkg or cdn.redoc.ly, on by default. No
+	// bundle is embedded, without the cdn set assetsUrl or WithOpenAPIAssets.
+	CDN bool `yaml:"cdn"`
+	// AssetsURL overrides the location the ui bundle is loaded from
+	AssetsURL string `yaml:"assetsUrl"`
+
+	// ui bundle served by the gateway, set with WithOpenAPIAssets
+	assets fs.FS
 }
 
 type ServerConfig struct {
-	Disabled bool
-	Addr     string
-	TLS      TlsConfig
+	Disabled bool      `yaml:"disabled"`
+	Addr     string    `yaml:"addr"`
+	TLS      TlsConfig `yaml:"tls"`
 }
 
 type OtelConfig struct {
-	OtelExporterConfig
-	Tracing    OtelExporterConfig
-	Metrics    OtelExporterConfig
-	Logging    OtelExporterConfig
-	TracerName string
-	LoggerName string
+	OtelExporterConfig `yaml:",inline"`
+	Tracing            OtelExporterConfig `yaml:"tracing"`
+	Metrics            OtelExporterConfig `yaml:"metrics"`
+	Logging            OtelExporterConfig `yaml:"logging"`
+	Resource           ResourceConfig     `yaml:"resource"`
+	Sampler            SamplerConfig      `yaml:"sampler"`
+	Prometheus         PrometheusConfig   `yaml:"prometheus"`
+	TracerName         string             `yaml:"tracerName"`
+	LoggerName         string             `yaml:"loggerName"`
+	// any of tracecontext, baggage, b3, b3multi, jaeger, ottrace, xray or
+	// none, falls back to OTEL_PROPAGATORS
+	Propagators []string `yaml:"propagators"`
+	// metrics recorded by boilerplate itself, if metrics are enabled
+	Instrumentation InstrumentationConfig `yaml:"instrumentation"`
+	Views           []ViewConfig          `yaml:"views"`
+}
+
+// ViewConfig changes how the metrics of the matching instruments are recorded
+type ViewConfig struct {
+	// name of the instrument, may contain * and ? wildcards
+	Instrument string `yaml:"instrument"`
+	// only match instruments of this meter (instrumentation scope)
+	Meter string `yaml:"meter"`
+
+	// rename is only allowed for views without wildcards
+	Rename      string `yaml:"rename"`
+	Description string `yaml:"description"`
+	Drop        bool   `yaml:"drop"`
+	// attributes to keep, all others are dropped
+	AllowAttributes []string `yaml:"allowAttributes"`
+	// attributes to drop, ignored if allowAttributes is set
+	DenyAttributes []string `yaml:"denyAttributes"`
+	// any of default, sum, lastvalue, histogram, exponential_histogram
+	Aggregation string    `yaml:"aggregation"`
+	Buckets     []float64 `yaml:"buckets"`
+	// maximum number of distinct values per attribute, further values are
+	// recorded without the attribute
+	CardinalityLimit int `yaml:"cardinalityLimit"`
+}
+
+type InstrumentationConfig struct {
+	// go runtime metrics: gc, goroutines, memory and scheduler latency
+	Runtime bool `yaml:"runtime"`
+	// host and process cpu, memory and network metrics
+	Host bool `yaml:"host"`
+	// boilerplate.build.info gauge with version, vcs revision and go version
+	BuildInfo bool `yaml:"buildInfo"`
+	// bucket boundaries by instrument name, e.g. rpc.server.duration, names
+	// may contain * wildcards
+	HistogramBuckets map[string][]float64 `yaml:"histogramBuckets"`
+}
+
+type ResourceConfig struct {
+	ServiceVersion        string            `yaml:"serviceVersion"`
+	ServiceNamespace      string            `yaml:"serviceNamespace"`
+	ServiceInstanceID     string            `yaml:"serviceInstanceId"`
+	DeploymentEnvironment string            `yaml:"deploymentEnvironment"`
+	Attributes            map[string]string `yaml:"attributes"`
+	// any of host, os, process, container, kubernetes
+	Detectors []string `yaml:"detectors"`
+}
+
+type SamplerConfig struct {
+	// any of always_on, always_off, traceidratio and their parentbased_
+	// variants, falls back to OTEL_TRACES_SAMPLER
+	Type string `yaml:"type"`
+	// share of traces the traceidratio samplers keep, defaults to 1
+	Ratio float64 `yaml:"ratio"`
+	// rules are matched against the span name in order, the first match wins
+	Rules []SamplerRule `yaml:"rules"`
+	// export spans that end with an error status, even if they were not sampled
+	KeepErrors bool `yaml:"keepErrors"`
+	// maximum number of sampled root spans per second, 0 disables the limit
+	RateLimit float64 `yaml:"rateLimit"`
+}
+
+// PrometheusConfig applies if the metrics protocol is prometheus. Metrics are
+// served on the admin listener if enabled, on the gateway otherwise.
+type PrometheusConfig struct {
+	Path string `yaml:"path"`
+}
+
+func (c PrometheusConfig) path() string {
+	if c.Path != "" {
+		return c.Path
+	}
+	return DEFAULT_PROMETHEUS_PATH
+}
+
+type SamplerRule struct {
+	// glob pattern, e.g. "grpc.health.v1.Health/*"
+	Span  string  `yaml:"span"`
+	Ratio float64 `yaml:"ratio"`
 }
 
+// OtelExporterConfig configures an exporter. Settings of the tracing, metrics
+// and logging exporters take precedence over the general ones.
 type OtelExporterConfig struct {
-	Enabled  bool
-	Addr     string
-	Interval uint
-	Protocol string
-	Insecure bool
+	Enabled  bool          `yaml:"enabled"`
+	Addr     string        `yaml:"addr"`
+	Interval time.Duration `yaml:"interval"`
+	Protocol string        `yaml:"protocol"`
+	Insecure bool          `yaml:"insecure"`
+	TLS      OtelTLSConfig `yaml:"tls"`
+	// values are either literal, or read from an environment variable
+	// (env:NAME) or a file (file:/path)
+	Headers map[string]string `yaml:"headers" redact:"true"`
+	// gzip or none
+	Compression string          `yaml:"compression"`
+	Timeout     time.Duration   `yaml:"timeout"`
+	Retry       OtelRetryConfig `yaml:"retry"`
+	// path of the http exporters, defaults to /v1/traces, /v1/metrics and
+	// /v1/logs
+	URLPath string `yaml:"urlPath"`
+}
+
+// OtelTLSConfig verifies the collector with a private ca and optionally
+// authenticates with a client certificate
+type OtelTLSConfig struct {
+	Ca         string `yaml:"ca"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"serverName"`
+}
+
+func (c OtelTLSConfig) enabled() bool {
+	return c.Ca != "" || c.Cert != "" || c.ServerName != ""
+}
+
+// OtelRetryConfig controls retrying failed exports, zero intervals keep the
+// exporter defaults
+type OtelRetryConfig struct {
+	Disabled        bool          `yaml:"disabled"`
+	InitialInterval time.Duration `yaml:"initialInterval"`
+	MaxInterval     time.Duration `yaml:"maxInterval"`
+	MaxElapsedTime  time.Duration `yaml:"maxElapsedTime"`
 }
 
 type TlsConfig struct {
-	Enabled bool
-	Mutual  bool
-	Key     string
-	Cert    string
-	Ca      string
+	Enabled bool   `yaml:"enabled"`
+	Mutual  bool   `yaml:"mutual"`
+	Key     string `yaml:"key"`
+	Cert    string `yaml:"cert"`
+	Ca      string `yaml:"ca"`
+}
+
+// ResolvedServiceName falls back to OTEL_SERVICE_NAME and then to the default
+func (c BoilerplateConfig) ResolvedServiceName() string {
+	if c.ServiceName != "" {
+		return c.ServiceName
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return DEFAULT_SERVICE_NAME
+}
+
+func (c GatewayConfig) readHeaderTimeout() time.Duration {
+	if c.ReadHeaderTimeout != 0 {
+		return c.ReadHeaderTimeout
+	}
+	return DEFAULT_GATEWAY_READ_HEADER_TIMEOUT
+}
+
+func (c OtelConfig) TracingEnabled() bool {
+	return c.Enabled && c.Tracing.Enabled && !signalDisabledByEnv(SIGNAL_TRACES)
+}
+
+func (c OtelConfig) MetricsEnabled() bool {
+	return c.Enabled && c.Metrics.Enabled && !signalDisabledByEnv(SIGNAL_METRICS)
+}
+
+func (c OtelConfig) LoggingEnabled() bool {
+	return c.Enabled && c.Logging.Enabled && !signalDisabledByEnv(SIGNAL_LOGS)
 }
 
 func (c OtelConfig) TracingAddr() string {
@@ -106,6 +510,9 @@ func (c OtelConfig) TracingAddr() string {
 	if c.Addr != "" {
 		return c.Addr
 	}
+	if e := otlpEnvEndpoint(SIGNAL_TRACES); e.addr != "" {
+		return e.addr
+	}
 	return DEFAULT_OTEL_ADDR
 }
 
@@ -116,6 +523,9 @@ func (c OtelConfig) MetricsAddr() string {
 	if c.Addr != "" {
 		return c.Addr
 	}
+	if e := otlpEnvEndpoint(SIGNAL_METRICS); e.addr != "" {
+		return e.addr
+	}
 	return DEFAULT_OTEL_ADDR
 }
 
@@ -126,6 +536,9 @@ func (c OtelConfig) LoggingAddr() string {
 	if c.Addr != "" {
 		return c.Addr
 	}
+	if e := otlpEnvEndpoint(SIGNAL_LOGS); e.addr != "" {
+		return e.addr
+	}
 	return DEFAULT_OTEL_ADDR
 }
 
@@ -133,64 +546,143 @@ func (c OtelConfig) TracingProtocol() string {
 	if c.Tracing.Protocol != "" {
 		return c.Tracing.Protocol
 	}
-	return c.Protocol
+	if c.Protocol != "" {
+		return c.Protocol
+	}
+	if protocol := otlpEnvProtocol(SIGNAL_TRACES); protocol != "" {
+		return protocol
+	}
+	return DEFAULT_OTEL_PROTOCOL
 }
 
 func (c OtelConfig) MetricsProtocol() string {
 	if c.Metrics.Protocol != "" {
 		return c.Metrics.Protocol
 	}
-	return c.Protocol
+	if c.Protocol != "" {
+		return c.Protocol
+	}
+	if protocol := otlpEnvProtocol(SIGNAL_METRICS); protocol != "" {
+		return protocol
+	}
+	return DEFAULT_OTEL_PROTOCOL
 }
 
 func (c OtelConfig) LoggingProtocol() string {
 	if c.Logging.Protocol != "" {
 		return c.Logging.Protocol
 	}
-	return c.Protocol
+	if c.Protocol != "" {
+		return c.Protocol
+	}
+	if protocol := otlpEnvProtocol(SIGNAL_LOGS); protocol != "" {
+		return protocol
+	}
+	return DEFAULT_OTEL_PROTOCOL
 }
 
 func (c OtelConfig) TracingInterval() time.Duration {
 	if c.Tracing.Interval != 0 {
-		return time.Second * time.Duration(c.Tracing.Interval)
+		return c.Tracing.Interval
 	}
 
 	if c.Interval != 0 {
-		return time.Second * time.Duration(c.Interval)
+		return c.Interval
 	}
-	return DEFAULT_OTEL_INTERVAL * time.Second
+
+	if interval := intervalEnv(SIGNAL_TRACES); interval != 0 {
+		return interval
+	}
+	return DEFAULT_OTEL_INTERVAL
 }
 
 func (c OtelConfig) MetricsInterval() time.Duration {
 	if c.Metrics.Interval != 0 {
-		return time.Second * time.Duration(c.Metrics.Interval)
+		return c.Metrics.Interval
 	}
 
 	if c.Interval != 0 {
-		return time.Second * time.Duration(c.Interval)
+		return c.Interval
+	}
+
+	if interval := intervalEnv(SIGNAL_METRICS); interval != 0 {
+		return interval
 	}
-	return DEFAULT_OTEL_INTERVAL * time.Second
+	return DEFAULT_OTEL_INTERVAL
 }
 
 func (c OtelConfig) LoggingInterval() time.Duration {
 	if c.Logging.Interval != 0 {
-		return time.Second * time.Duration(c.Logging.Interval)
+		return c.Logging.Interval
 	}
 
 	if c.Interval != 0 {
-		return time.Second * time.Duration(c.Interval)
+		return c.Interval
 	}
-	return DEFAULT_OTEL_INTERVAL * time.Second
+
+	if interval := intervalEnv(SIGNAL_LOGS); interval != 0 {
+		return interval
+	}
+	return DEFAULT_OTEL_INTERVAL
 }
 
 func (c OtelConfig) TracingInsecure() bool {
-	return c.Insecure || c.Tracing.Insecure
+	return c.Insecure || c.Tracing.Insecure || c.envInsecure(SIGNAL_TRACES, c.Tracing)
 }
 
 func (c OtelConfig) MetricsInsecure() bool {
-	return c.Insecure || c.Metrics.Insecure
+	return c.Insecure || c.Metrics.Insecure || c.envInsecure(SIGNAL_METRICS, c.Metrics)
 }
 
 func (c OtelConfig) LoggingInsecure() bool {
-	return c.Insecure || c.Logging.Insecure
+	return c.Insecure || c.Logging.Insecure || c.envInsecure(SIGNAL_LOGS, c.Logging)
+}
+
+// envInsecure applies the OTEL_* insecure settings only to an address taken
+// from the environment as well
+func (c OtelConfig) envInsecure(name string, signal OtelExporterConfig) bool {
+	if signal.Addr != "" || c.Addr != "" {
+		return false
+	}
+	return otlpEnvInsecure(name)
+}
+
+// exporterSettings merges the tls, header, compression, timeout, retry and
+// url path settings of a signal over the general ones. A general url path is
+// used as prefix of the signal's default path.
+func (c OtelConfig) exporterSettings(name string, signal OtelExporterConfig) OtelExporterConfig {
+	general := c.OtelExporterConfig
+	merged := signal
+
+	merged.TLS = OtelTLSConfig{
+		Ca:         cmp.Or(signal.TLS.Ca, general.TLS.Ca),
+		Cert:       cmp.Or(signal.TLS.Cert, general.TLS.Cert),
+		Key:        cmp.Or(signal.TLS.Key, general.TLS.Key),
+		ServerName: cmp.Or(signal.TLS.ServerName, general.TLS.ServerName),
+	}
+
+	if len(general.Headers) > 0 {
+		merged.Headers = maps.Clone(general.Headers)
+		maps.Copy(merged.Headers, signal.Headers)
+	}
+
+	merged.Compression = cmp.Or(signal.Compression, general.Compression)
+	merged.Timeout = cmp.Or(signal.Timeout, general.Timeout)
+
+	merged.Retry = OtelRetryConfig{
+		Disabled:        signal.Retry.Disabled || general.Retry.Disabled,
+		InitialInterval: cmp.Or(signal.Retry.InitialInterval, general.Retry.InitialInterval),
+		MaxInterval:     cmp.Or(signal.Retry.MaxInterval, general.Retry.MaxInterval),
+		MaxElapsedTime:  cmp.Or(signal.Retry.MaxElapsedTime, general.Retry.MaxElapsedTime),
+	}
+
+	if merged.URLPath == "" && general.URLPath != "" {
+		merged.URLPath = path.Join(general.URLPath, otlpURLPaths[name])
+	}
+
+	// the path of an endpoint from the environment belongs to its address
+	if merged.URLPath == "" && signal.Addr == "" && general.Addr == "" {
+		merged.URLPath = otlpEnvEndpoint(name).urlPath
+	}
+	return merged
 }
@@ -3,9 +3,28 @@ package boilerplate
 import (
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
-func corsHandler(origins []string, methods []string, headers []string) func(http.Handler) http.Handler {
+type corsSettings struct {
+	origins string
+	methods string
+	headers string
+}
+
+// cors holds the allowed origins, methods and headers behind an atomic
+// pointer, so they can be swapped on config reload
+type cors struct {
+	settings atomic.Pointer[corsSettings]
+}
+
+func newCors(origins []string, methods []string, headers []string) *cors {
+	c := &cors{}
+	c.update(origins, methods, headers)
+	return c
+}
+
+func (c *cors) update(origins []string, methods []string, headers []string) {
 
 	allowedOrigins := "*"
 	if len(origins) > 0 {
@@ -18,20 +37,27 @@ func corsHandler(origins []string, methods []string, headers []string) func(http
 	}
 
 	allowedHeaders := "*"
-	if len(origins) > 0 {
+	if len(headers) > 0 {
 		allowedHeaders = strings.Join(headers, ", ")
 	}
 
-	return func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
-			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
-			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-			if r.Method == http.MethodOptions {
-				w.WriteHeader(http.StatusNoContent)
-				return
-			}
-			h.ServeHTTP(w, r)
-		})
-	}
+	c.settings.Store(&corsSettings{
+		origins: allowedOrigins,
+		methods: allowedMethods,
+		headers: allowedHeaders,
+	})
+}
+
+func (c *cors) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := c.settings.Load()
+		w.Header().Set("Access-Control-Allow-Origin", settings.origins)
+		w.Header().Set("Access-Control-Allow-Methods", settings.methods)
+		w.Header().Set("Access-Control-Allow-Headers", settings.headers)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
 }
@@ -9,7 +9,6 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/sekthor/boilerplate"
 	greeterv1 "github.com/sekthor/boilerplate/example/greeter/v1"
-	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
@@ -37,7 +36,7 @@ func (i *ServiceImplementation) SayHello(ctx context.Context, req *greeterv1.Say
 		name = name + " (" + claims.Subject + ")"
 	}
 
-	logrus.WithContext(ctx).Info("said hello")
+	i.server.Logger().InfoContext(ctx, "said hello")
 
 	return &greeterv1.SayHelloResponse{
 		Message: fmt.Sprintf("Hello %s!", name),
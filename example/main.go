@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"embed"
 	"fmt"
 	"log"
 
@@ -11,6 +12,9 @@ import (
 	"google.golang.org/grpc"
 )
 
+//go:embed greeter/v1/greeter.swagger.json
+var openapi embed.FS
+
 type ServiceImplementation struct {
 	greeterv1.UnimplementedGreeterServiceServer
 	server boilerplate.BoilerplateServer
@@ -48,6 +52,7 @@ func main() {
 	server := boilerplate.Default()
 	server.RegisterGrpc(service.GrpcFunc())
 	server.RegisterGateway(service.GatewayFunc())
+	server.AddOpenAPISpec(openapi, "greeter/v1/greeter.swagger.json")
 	service.server = server
 
 	ctx := context.Background()
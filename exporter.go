@@ -0,0 +1,129 @@
+package boilerplate
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// otlpSettings holds the resolved settings shared by the otlp exporters of
+// all signals
+type otlpSettings struct {
+	OtelExporterConfig
+	headers   map[string]string
+	tlsConfig *tls.Config
+}
+
+// retryConfig mirrors the RetryConfig of the otlp exporter packages, so it
+// can be converted to each of them
+type retryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+func newOtlpSettings(conf OtelConfig, name string, signal OtelExporterConfig) (otlpSettings, error) {
+	settings := otlpSettings{
+		OtelExporterConfig: conf.exporterSettings(name, signal),
+	}
+
+	headers, err := resolveHeaders(settings.Headers)
+	if err != nil {
+		return settings, err
+	}
+	settings.headers = headers
+
+	if settings.TLS.enabled() {
+		settings.tlsConfig, err = otlpTLSConfig(settings.TLS)
+	}
+	return settings, err
+}
+
+func (s otlpSettings) gzip() bool {
+	return s.Compression == "gzip"
+}
+
+// retrySet reports whether any retry setting differs from the exporter default
+func (s otlpSettings) retrySet() bool {
+	return s.Retry != OtelRetryConfig{}
+}
+
+func (s otlpSettings) retry() retryConfig {
+	return retryConfig{
+		Enabled:         !s.Retry.Disabled,
+		InitialInterval: cmp.Or(s.Retry.InitialInterval, DEFAULT_OTEL_RETRY_INITIAL_INTERVAL),
+		MaxInterval:     cmp.Or(s.Retry.MaxInterval, DEFAULT_OTEL_RETRY_MAX_INTERVAL),
+		MaxElapsedTime:  cmp.Or(s.Retry.MaxElapsedTime, DEFAULT_OTEL_RETRY_MAX_ELAPSED_TIME),
+	}
+}
+
+// resolveHeaders reads header values referencing an environment variable
+// (env:NAME) or a file (file:/path), so secrets stay out of the config file
+func resolveHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	resolved := make(map[string]string, len(headers))
+	for key, value := range headers {
+		v, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("header '%s': %w", key, err)
+		}
+		resolved[key] = v
+	}
+	return resolved, nil
+}
+
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, "file:"):
+		content, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+	return value, nil
+}
+
+func otlpTLSConfig(conf OtelTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: conf.ServerName,
+	}
+
+	if conf.Ca != "" {
+		caBytes, err := os.ReadFile(conf.Ca)
+		if err != nil {
+			return nil, err
+		}
+		ca := x509.NewCertPool()
+		if ok := ca.AppendCertsFromPEM(caBytes); !ok {
+			return nil, errors.New("could not load otel exporter ca cert")
+		}
+		tlsConfig.RootCAs = ca
+	}
+
+	if conf.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.Cert, conf.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
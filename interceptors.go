@@ -0,0 +1,18 @@
+package boilerplate
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// wrappedServerStream allows stream interceptors to hand an enriched context
+// down to the handler
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedServerStream) Context() context.Context {
+	return s.ctx
+}
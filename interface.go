@@ -2,6 +2,8 @@ package boilerplate
 
 import (
 	"context"
+	"io/fs"
+	"log/slog"
 
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
@@ -10,14 +12,20 @@ import (
 type BoilerplateServer interface {
 	WithServiceName(string) *boilerplate
 	WithConfig(BoilerplateConfig) *boilerplate
+	WithConfigFile(string) *boilerplate
+	OnConfigChange(ConfigSubscriber) *boilerplate
 	WithGrpcAddr(string) *boilerplate
 	WithGatewayAddr(string) *boilerplate
 	WithGrpcRegisterFunc(GrpcRegisterFunc) *boilerplate
 	WithGatewayRegisterFunc(GatewayRegisterFunc) *boilerplate
 	WithTracer(string) *boilerplate
 	AddInterceptor(grpc.UnaryServerInterceptor) *boilerplate
+	AddStreamInterceptor(grpc.StreamServerInterceptor) *boilerplate
+	AddOpenAPISpec(fs.FS, ...string) *boilerplate
 	RegisterGateway(GatewayRegisterFunc)
 	RegisterGrpc(GrpcRegisterFunc)
 	Run(context.Context) error
 	Tracer() trace.Tracer
+	Logger() *slog.Logger
+	Config() BoilerplateConfig
 }
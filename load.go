@@ -0,0 +1,91 @@
+package boilerplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the on-disk layout: the boilerplate settings at the top level
+// and a free-form section for the service's own settings
+type configFile struct {
+	BoilerplateConfig `yaml:",inline"`
+	Extension         yaml.Node `yaml:"extension"`
+}
+
+// LoadConfig reads a yaml, json or toml file and layers it over the defaults.
+// Durations are written as strings, e.g. "5s" or "1m30s".
+func LoadConfig(path string) (BoilerplateConfig, error) {
+	return LoadConfigInto(path, nil)
+}
+
+// LoadConfigInto works like LoadConfig, but also decodes the file's
+// "extension" section into the given struct pointer.
+func LoadConfigInto(path string, extension any) (BoilerplateConfig, error) {
+	file := configFile{BoilerplateConfig: defaultConfig}
+
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return file.BoilerplateConfig, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return file.BoilerplateConfig, fmt.Errorf("could not parse config file '%s': %w", path, redactYAMLError(err))
+	}
+
+	if extension != nil && !file.Extension.IsZero() {
+		if err := file.Extension.Decode(extension); err != nil {
+			return file.BoilerplateConfig, fmt.Errorf("could not parse extension section of '%s': %w", path, redactYAMLError(err))
+		}
+	}
+
+	return file.BoilerplateConfig, nil
+}
+
+// yamlErrorValue matches the value yaml quotes in type errors
+var yamlErrorValue = regexp.MustCompile(" `.*`")
+
+// redactYAMLError drops the values from type errors. Any field may hold a
+// secret, the ones of the extension section are not even known.
+func redactYAMLError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	redacted := &yaml.TypeError{Errors: make([]string, len(typeErr.Errors))}
+	for i, msg := range typeErr.Errors {
+		redacted.Errors[i] = yamlErrorValue.ReplaceAllString(msg, "")
+	}
+	return redacted
+}
+
+// readConfigFile returns the file content as yaml. Json is valid yaml and
+// toml is converted, so all formats share the yaml struct tags.
+func readConfigFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return raw, nil
+	case ".toml":
+		var doc map[string]any
+		if err := toml.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("could not parse config file '%s': %w", path, err)
+		}
+		return yaml.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file format '%s'", filepath.Ext(path))
+	}
+}
@@ -0,0 +1,220 @@
+package boilerplate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		check   func(t *testing.T, conf BoilerplateConfig)
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+serviceName: greeter
+grpc:
+  addr: ":6000"
+  connectionTimeout: 1m30s
+otel:
+  tracing:
+    protocol: http
+`,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.ServiceName != "greeter" || conf.Grpc.Addr != ":6000" || conf.Otel.Tracing.Protocol != "http" {
+					t.Errorf("file values not applied: %q %q %q", conf.ServiceName, conf.Grpc.Addr, conf.Otel.Tracing.Protocol)
+				}
+				if conf.Grpc.ConnectionTimeout != 90*time.Second {
+					t.Errorf("got connectionTimeout %s, want 1m30s", conf.Grpc.ConnectionTimeout)
+				}
+				if conf.Gateway.Addr != DEFAULT_GATEWAY_ADDR {
+					t.Errorf("got gateway addr %q, want the default %q", conf.Gateway.Addr, DEFAULT_GATEWAY_ADDR)
+				}
+			},
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: `{"serviceName": "greeter", "gateway": {"requestTimeout": "5s"}}`,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.ServiceName != "greeter" || conf.Gateway.RequestTimeout != 5*time.Second {
+					t.Errorf("file values not applied: %q %s", conf.ServiceName, conf.Gateway.RequestTimeout)
+				}
+			},
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+serviceName = "greeter"
+
+[grpc]
+addr = ":6000"
+maxRecvMsgSize = 1024
+
+[otel.tracing]
+interval = "10s"
+`,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.ServiceName != "greeter" || conf.Grpc.Addr != ":6000" || conf.Grpc.MaxRecvMsgSize != 1024 {
+					t.Errorf("file values not applied: %q %q %d", conf.ServiceName, conf.Grpc.Addr, conf.Grpc.MaxRecvMsgSize)
+				}
+				if conf.Otel.Tracing.Interval != 10*time.Second {
+					t.Errorf("got tracing interval %s, want 10s", conf.Otel.Tracing.Interval)
+				}
+			},
+		},
+		{
+			name:    "unknown yaml field",
+			file:    "config.yaml",
+			content: "grpc:\n  adr: \":6000\"\n",
+			wantErr: "field adr not found",
+		},
+		{
+			name:    "unknown toml field",
+			file:    "config.toml",
+			content: "[grpc]\nadr = \":6000\"\n",
+			wantErr: "field adr not found",
+		},
+		{
+			name:    "unsupported format",
+			file:    "config.ini",
+			content: "serviceName=greeter",
+			wantErr: "unsupported config file format '.ini'",
+		},
+		{
+			name:    "invalid duration",
+			file:    "config.yaml",
+			content: "grpc:\n  connectionTimeout: soon\n",
+			wantErr: "cannot unmarshal !!str into time.Duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := LoadConfig(writeConfigFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, conf)
+		})
+	}
+}
+
+func TestLoadConfigInto(t *testing.T) {
+	type extension struct {
+		Greeting string `yaml:"greeting"`
+		Password string `yaml:"password"`
+		Retries  int    `yaml:"retries"`
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    extension
+		wantErr string
+	}{
+		{
+			name:    "extension section",
+			content: "serviceName: greeter\nextension:\n  greeting: Hello\n  retries: 3\n",
+			want:    extension{Greeting: "Hello", Retries: 3},
+		},
+		{
+			name:    "no extension section",
+			content: "serviceName: greeter\n",
+		},
+		{
+			name:    "invalid extension value",
+			content: "extension:\n  retries: secret\n",
+			wantErr: "could not parse extension section",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got extension
+			conf, err := LoadConfigInto(writeConfigFile(t, "config.yaml", tt.content), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if conf.ServiceName != "greeter" {
+				t.Errorf("got serviceName %q, want greeter", conf.ServiceName)
+			}
+			if got != tt.want {
+				t.Errorf("got extension %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRedactsValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		load    func(path string) error
+	}{
+		{
+			name:    "LoadConfig",
+			content: "otel:\n  headers: s3cr3t\n",
+			load: func(path string) error {
+				_, err := LoadConfig(path)
+				return err
+			},
+		},
+		{
+			name:    "LoadConfigInto",
+			content: "extension:\n  retries: s3cr3t\n",
+			load: func(path string) error {
+				var extension struct {
+					Retries int `yaml:"retries"`
+				}
+				_, err := LoadConfigInto(path, &extension)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.load(writeConfigFile(t, "config.yaml", tt.content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if strings.Contains(err.Error(), "s3cr3t") {
+				t.Errorf("error contains the value: %v", err)
+			}
+			if !strings.Contains(err.Error(), "line 2: cannot unmarshal") {
+				t.Errorf("error lost its location: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package boilerplate
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/bridges/otellogrus"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var validLogFormats = []string{"text", "logfmt", "json"}
+
+// newLogHandler creates the handler of the logger boilerplate uses if none
+// is passed to the builder
+func newLogHandler(conf LogConfig, w io.Writer, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, AddSource: conf.Source}
+	if conf.Format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	// slog's text output is logfmt
+	return slog.NewTextHandler(w, opts)
+}
+
+// logBackend is where boilerplate writes its logs to. Each backend wires the
+// otel log bridge its own way, so that application logs written to the same
+// backend are exported as well.
+type logBackend interface {
+	// logger returns the logger boilerplate logs through, bridged to otel if
+	// a logger provider is given
+	logger(name string, lp otellog.LoggerProvider) *slog.Logger
+}
+
+type slogBackend struct {
+	log *slog.Logger
+}
+
+func (b slogBackend) logger(name string, lp otellog.LoggerProvider) *slog.Logger {
+	if lp == nil {
+		return b.log
+	}
+	return slog.New(fanoutHandler{
+		b.log.Handler(),
+		otelslog.NewHandler(name, otelslog.WithLoggerProvider(lp)),
+	})
+}
+
+type logrusBackend struct {
+	log *logrus.Logger
+}
+
+func (b logrusBackend) logger(name string, lp otellog.LoggerProvider) *slog.Logger {
+	if lp != nil {
+		b.log.AddHook(otellogrus.NewHook(name, otellogrus.WithLoggerProvider(lp)))
+	}
+	return slog.New(&logrusHandler{log: b.log})
+}
+
+type zapBackend struct {
+	log *zap.Logger
+}
+
+func (b zapBackend) logger(name string, lp otellog.LoggerProvider) *slog.Logger {
+	log := b.log
+	if lp != nil {
+		bridge := otelzap.NewCore(name, otelzap.WithLoggerProvider(lp))
+		log = log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, bridge)
+		}))
+	}
+	return slog.New(&zapHandler{log: log})
+}
+
+// contextHandler filters records by the runtime adjustable level and adds
+// the trace, span and request id of the context to each record
+type contextHandler struct {
+	slog.Handler
+	level slog.Leveler
+	// base and ops rebuild the handler with the ids outside of any group
+	base    slog.Handler
+	ops     []func(slog.Handler) slog.Handler
+	grouped bool
+}
+
+func newContextHandler(h slog.Handler, level slog.Leveler) contextHandler {
+	return contextHandler{Handler: h, level: level, base: h}
+}
+
+func (h contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	ids := contextIDs(ctx)
+	if len(ids) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	if !h.grouped {
+		r = r.Clone()
+		r.AddAttrs(ids...)
+		return h.Handler.Handle(ctx, r)
+	}
+
+	handler := h.base.WithAttrs(ids)
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	}, false)
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	}, name != "")
+}
+
+func (h contextHandler) with(op func(slog.Handler) slog.Handler, group bool) contextHandler {
+	return contextHandler{
+		Handler: op(h.Handler),
+		level:   h.level,
+		base:    h.base,
+		ops:     append(h.ops[:len(h.ops):len(h.ops)], op),
+		grouped: h.grouped || group,
+	}
+}
+
+func contextIDs(ctx context.Context) []slog.Attr {
+	var ids []slog.Attr
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		ids = append(ids,
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()))
+	}
+	if id, ok := GetRequestIDFromContext(ctx); ok {
+		ids = append(ids, slog.String("request_id", id))
+	}
+	return ids
+}
+
+// fanoutHandler hands every record to all of its handlers
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			if handlerErr := handler.Handle(ctx, r.Clone()); handlerErr != nil {
+				err = handlerErr
+			}
+		}
+	}
+	return err
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+// attrCollector flattens attributes and groups into dotted keys, for backends
+// without a notion of groups
+type attrCollector struct {
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (c attrCollector) withAttrs(attrs []slog.Attr) attrCollector {
+	collected := append([]slog.Attr{}, c.attrs...)
+	for _, attr := range attrs {
+		collected = flattenAttr(collected, c.prefix, attr)
+	}
+	return attrCollector{attrs: collected, prefix: c.prefix}
+}
+
+func (c attrCollector) withGroup(name string) attrCollector {
+	if name == "" {
+		return c
+	}
+	return attrCollector{attrs: c.attrs, prefix: c.prefix + name + "."}
+}
+
+func (c attrCollector) record(r slog.Record) []slog.Attr {
+	attrs := append([]slog.Attr{}, c.attrs...)
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = flattenAttr(attrs, c.prefix, attr)
+		return true
+	})
+	return attrs
+}
+
+func flattenAttr(attrs []slog.Attr, prefix string, attr slog.Attr) []slog.Attr {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			attrs = flattenAttr(attrs, groupPrefix, member)
+		}
+		return attrs
+	}
+	if attr.Key == "" {
+		return attrs
+	}
+	return append(attrs, slog.Attr{Key: prefix + attr.Key, Value: value})
+}
+
+// logrusHandler writes slog records to a logrus logger
+type logrusHandler struct {
+	log   *logrus.Logger
+	attrs attrCollector
+}
+
+func (h *logrusHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.log.IsLevelEnabled(logrusLevel(level))
+}
+
+func (h *logrusHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := h.attrs.record(r)
+	fields := make(logrus.Fields, len(attrs))
+	for _, attr := range attrs {
+		fields[attr.Key] = attr.Value.Any()
+	}
+	h.log.WithContext(ctx).WithTime(r.Time).WithFields(fields).Log(logrusLevel(r.Level), r.Message)
+	return nil
+}
+
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logrusHandler{log: h.log, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	return &logrusHandler{log: h.log, attrs: h.attrs.withGroup(name)}
+}
+
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	}
+	return logrus.TraceLevel
+}
+
+// zapHandler writes slog records to a zap logger
+type zapHandler struct {
+	log   *zap.Logger
+	attrs attrCollector
+}
+
+func (h *zapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.log.Core().Enabled(zapLevel(level))
+}
+
+func (h *zapHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := h.log.Check(zapLevel(r.Level), r.Message)
+	if entry == nil {
+		return nil
+	}
+	entry.Time = r.Time
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+
+	attrs := h.attrs.record(r)
+	fields := make([]zap.Field, 0, len(attrs)+1)
+	for _, attr := range attrs {
+		fields = append(fields, zap.Any(attr.Key, attr.Value.Any()))
+	}
+	if ctx != nil {
+		// the otelzap core takes the context from a field, skipped by encoders
+		fields = append(fields, zap.Field{Key: "context", Type: zapcore.SkipType, Interface: ctx})
+	}
+	entry.Write(fields...)
+	return nil
+}
+
+func (h *zapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &zapHandler{log: h.log, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *zapHandler) WithGroup(name string) slog.Handler {
+	return &zapHandler{log: h.log, attrs: h.attrs.withGroup(name)}
+}
+
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	}
+	return zapcore.DebugLevel
+}
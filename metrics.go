@@ -0,0 +1,161 @@
+package boilerplate
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	otelruntime "go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const instrumentationName = "github.com/sekthor/boilerplate"
+
+// startBuiltinMetrics registers the metrics that are recorded without any
+// code in the service
+func startBuiltinMetrics(mp metric.MeterProvider, conf OtelConfig) error {
+	if conf.Instrumentation.Runtime {
+		if err := otelruntime.Start(otelruntime.WithMeterProvider(mp)); err != nil {
+			return err
+		}
+	}
+
+	if conf.Instrumentation.Host {
+		if err := host.Start(host.WithMeterProvider(mp)); err != nil {
+			return err
+		}
+	}
+
+	if conf.Instrumentation.BuildInfo {
+		if err := startBuildInfoMetric(mp, conf.Resource.ServiceVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startBuildInfoMetric reports a constant 1, the build is described by the
+// attributes
+func startBuildInfoMetric(mp metric.MeterProvider, version string) error {
+	attrs := []attribute.KeyValue{
+		attribute.String("go.version", runtime.Version()),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				attrs = append(attrs, attribute.String("vcs.revision", setting.Value))
+			}
+		}
+	}
+	if version != "" {
+		attrs = append(attrs, attribute.String("version", version))
+	}
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	_, err := mp.Meter(instrumentationName).Int64ObservableGauge("boilerplate.build.info",
+		metric.WithDescription("Build information of the running service."),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(1, set)
+			return nil
+		}))
+	return err
+}
+
+// requestMetrics records what the otelgrpc and otelhttp instrumentation does
+// not: the number of requests in flight
+type requestMetrics struct {
+	rpcActive  metric.Int64UpDownCounter
+	httpActive metric.Int64UpDownCounter
+}
+
+func newRequestMetrics(mp metric.MeterProvider) (*requestMetrics, error) {
+	meter := mp.Meter(instrumentationName)
+
+	rpcActive, err := meter.Int64UpDownCounter("rpc.server.active_requests",
+		metric.WithDescription("Number of grpc requests in flight."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+
+	httpActive, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of gateway requests in flight."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &requestMetrics{rpcActive: rpcActive, httpActive: httpActive}, nil
+}
+
+func (m *requestMetrics) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		attrs := metric.WithAttributes(rpcAttributes(info.FullMethod)...)
+		m.rpcActive.Add(ctx, 1, attrs)
+		defer m.rpcActive.Add(ctx, -1, attrs)
+		return handler(ctx, req)
+	}
+}
+
+func (m *requestMetrics) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		attrs := metric.WithAttributes(rpcAttributes(info.FullMethod)...)
+		m.rpcActive.Add(ctx, 1, attrs)
+		defer m.rpcActive.Add(ctx, -1, attrs)
+		return handler(srv, ss)
+	}
+}
+
+func (m *requestMetrics) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attrs := metric.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method))
+		m.httpActive.Add(r.Context(), 1, attrs)
+		defer m.httpActive.Add(r.Context(), -1, attrs)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// rpcAttributes splits a full method like /greeter.v1.GreeterService/SayHello
+func rpcAttributes(fullMethod string) []attribute.KeyValue {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return []attribute.KeyValue{
+		semconv.RPCSystemGRPC,
+		semconv.RPCService(service),
+		semconv.RPCMethod(method),
+	}
+}
+
+// authFailures uses the global meter provider, the jwt interceptors are
+// created independently of the server
+var authFailures = sync.OnceValue(func() metric.Int64Counter {
+	counter, err := otel.Meter(instrumentationName).Int64Counter("boilerplate.auth.failures",
+		metric.WithDescription("Number of requests rejected by the jwt interceptors."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return counter
+})
+
+func recordAuthFailure(ctx context.Context, err error) {
+	attrs := rpcAttributes("")
+	if method, ok := grpc.Method(ctx); ok {
+		attrs = rpcAttributes(method)
+	}
+	attrs = append(attrs, semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+	authFailures().Add(ctx, 1, metric.WithAttributes(attrs...))
+}
@@ -0,0 +1,257 @@
+package boilerplate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	DEFAULT_OPENAPI_PATH    = "/openapi.json"
+	DEFAULT_OPENAPI_UI_PATH = "/docs"
+	DEFAULT_SWAGGER_ASSETS  = "https://unpkg.com/swagger-ui-dist@5.17.14"
+	DEFAULT_REDOC_ASSETS    = "https://cdn.redoc.ly/redoc/v2.1.5/bundles"
+)
+
+type openapiSpec struct {
+	fsys fs.FS
+	path string
+}
+
+var swaggerPage = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: "{{.Spec}}", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`))
+
+var redocPage = template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+</head>
+<body>
+  <redoc spec-url="{{.Spec}}"></redoc>
+  <script src="{{.Assets}}/redoc.standalone.js"></script>
+</body>
+</html>
+`))
+
+func (s *boilerplate) registerOpenAPI(mux *http.ServeMux, config BoilerplateConfig) error {
+	conf := config.Gateway.OpenAPI
+
+	spec, err := mergeOpenAPISpecs(config.ResolvedServiceName(), s.openapiSpecs)
+	if err != nil {
+		return err
+	}
+
+	specPath := conf.Path
+	if specPath == "" {
+		specPath = DEFAULT_OPENAPI_PATH
+	}
+
+	logger := s.Logger()
+
+	mux.HandleFunc("GET "+specPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(spec); err != nil {
+			logger.ErrorContext(r.Context(), "could not write openapi spec", "error", err)
+		}
+	})
+
+	if conf.UI == "" {
+		return nil
+	}
+
+	uiPath := strings.TrimSuffix(conf.UIPath, "/")
+	if uiPath == "" {
+		uiPath = DEFAULT_OPENAPI_UI_PATH
+	}
+
+	var page *template.Template
+	var cdn string
+
+	switch conf.UI {
+	case "swagger":
+		page, cdn = swaggerPage, DEFAULT_SWAGGER_ASSETS
+	case "redoc":
+		page, cdn = redocPage, DEFAULT_REDOC_ASSETS
+	default:
+		return fmt.Errorf("unknown openapi ui '%s'", conf.UI)
+	}
+
+	assets := conf.AssetsURL
+	if assets == "" && conf.CDN {
+		assets = cdn
+	}
+
+	// serve the ui bundle from the registered file system
+	if conf.assets != nil {
+		assets = uiPath + "/assets"
+		mux.Handle("GET "+assets+"/", http.StripPrefix(assets, http.FileServerFS(conf.assets)))
+	}
+	if assets == "" {
+		return fmt.Errorf("no %s ui bundle, set gateway.openapi.cdn or gateway.openapi.assetsUrl", conf.UI)
+	}
+
+	data := struct {
+		Title  string
+		Spec   string
+		Assets string
+	}{
+		Title:  config.ResolvedServiceName(),
+		Spec:   specPath,
+		Assets: strings.TrimSuffix(assets, "/"),
+	}
+
+	mux.HandleFunc("GET "+uiPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := page.Execute(w, data); err != nil {
+			logger.ErrorContext(r.Context(), "could not render openapi ui", "error", err)
+		}
+	})
+
+	return nil
+}
+
+// mergeOpenAPISpecs combines the paths, definitions and components of all
+// registered documents into a single spec. Documents must share the same
+// major version.
+func mergeOpenAPISpecs(title string, specs []openapiSpec) ([]byte, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("openapi enabled, but no spec registered")
+	}
+
+	var merged map[string]any
+	var version string
+
+	for _, spec := range specs {
+		raw, err := fs.ReadFile(spec.fsys, spec.path)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc map[string]any
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("could not parse openapi spec '%s': %w", spec.path, err)
+		}
+
+		v := openapiVersion(doc)
+		if v == "" {
+			return nil, fmt.Errorf("'%s' is not an openapi document", spec.path)
+		}
+
+		if merged == nil {
+			merged = doc
+			version = v
+			continue
+		}
+
+		if v != version {
+			return nil, fmt.Errorf("cannot merge openapi v%s spec '%s' into v%s spec", v, spec.path, version)
+		}
+
+		for _, key := range []string{"paths", "definitions", "securityDefinitions", "parameters", "responses"} {
+			if err := mergeOpenAPIObject(merged, doc, key); err != nil {
+				return nil, fmt.Errorf("%s: %w", spec.path, err)
+			}
+		}
+
+		if components, ok := doc["components"].(map[string]any); ok {
+			target, ok := merged["components"].(map[string]any)
+			if !ok {
+				target = map[string]any{}
+				merged["components"] = target
+			}
+			for key := range components {
+				if err := mergeOpenAPIObject(target, components, key); err != nil {
+					return nil, fmt.Errorf("%s: components: %w", spec.path, err)
+				}
+			}
+		}
+
+		if tags, ok := doc["tags"].([]any); ok {
+			merged["tags"] = mergeOpenAPITags(merged["tags"], tags)
+		}
+	}
+
+	if len(specs) > 1 {
+		info, ok := merged["info"].(map[string]any)
+		if !ok {
+			info = map[string]any{}
+			merged["info"] = info
+		}
+		info["title"] = title
+	}
+
+	return json.Marshal(merged)
+}
+
+func openapiVersion(doc map[string]any) string {
+	if v, ok := doc["swagger"].(string); ok {
+		return strings.SplitN(v, ".", 2)[0]
+	}
+	if v, ok := doc["openapi"].(string); ok {
+		return strings.SplitN(v, ".", 2)[0]
+	}
+	return ""
+}
+
+func mergeOpenAPIObject(dst, src map[string]any, key string) error {
+	from, ok := src[key].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	to, ok := dst[key].(map[string]any)
+	if !ok {
+		to = map[string]any{}
+		dst[key] = to
+	}
+
+	for name, value := range from {
+		existing, ok := to[name]
+		if ok && !reflect.DeepEqual(existing, value) {
+			return fmt.Errorf("conflicting %s entry '%s'", key, name)
+		}
+		to[name] = value
+	}
+	return nil
+}
+
+func mergeOpenAPITags(dst any, from []any) []any {
+	to, _ := dst.([]any)
+
+	seen := map[any]bool{}
+	for _, tag := range to {
+		if t, ok := tag.(map[string]any); ok {
+			seen[t["name"]] = true
+		}
+	}
+
+	for _, tag := range from {
+		if t, ok := tag.(map[string]any); ok && seen[t["name"]] {
+			continue
+		}
+		to = append(to, tag)
+	}
+	return to
+}
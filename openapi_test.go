@@ -0,0 +1,267 @@
+package boilerplate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMergeOpenAPISpecs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"greeter.json": {Data: []byte(`{
+			"swagger": "2.0",
+			"info": {"title": "greeter", "version": "1"},
+			"tags": [{"name": "Greeter"}],
+			"paths": {"/v1/hello": {"post": {"operationId": "SayHello"}}},
+			"definitions": {"rpcStatus": {"type": "object"}, "HelloRequest": {"type": "object"}}
+		}`)},
+		"farewell.json": {Data: []byte(`{
+			"swagger": "2.0",
+			"info": {"title": "farewell", "version": "1"},
+			"tags": [{"name": "Greeter"}, {"name": "Farewell"}],
+			"paths": {"/v1/bye": {"post": {"operationId": "SayBye"}}},
+			"definitions": {"rpcStatus": {"type": "object"}, "ByeRequest": {"type": "object"}}
+		}`)},
+		"conflict.json": {Data: []byte(`{
+			"swagger": "2.0",
+			"paths": {"/v1/hello": {"get": {"operationId": "Other"}}}
+		}`)},
+		"users.json": {Data: []byte(`{
+			"openapi": "3.0.3",
+			"info": {"title": "users", "version": "1"},
+			"paths": {"/users": {"get": {}}},
+			"components": {"schemas": {"User": {"type": "object"}}}
+		}`)},
+		"groups.json": {Data: []byte(`{
+			"openapi": "3.1.0",
+			"info": {"title": "groups", "version": "1"},
+			"paths": {"/groups": {"get": {}}},
+			"components": {"schemas": {"Group": {"type": "object"}}, "securitySchemes": {"bearer": {"type": "http"}}}
+		}`)},
+		"invalid.json":    {Data: []byte(`{"swagger": `)},
+		"notopenapi.json": {Data: []byte(`{"paths": {}}`)},
+	}
+
+	specs := func(paths ...string) []openapiSpec {
+		var specs []openapiSpec
+		for _, path := range paths {
+			specs = append(specs, openapiSpec{fsys: fsys, path: path})
+		}
+		return specs
+	}
+
+	tests := []struct {
+		name    string
+		specs   []openapiSpec
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "single spec keeps its title",
+			specs: specs("users.json"),
+			want: `{
+				"openapi": "3.0.3",
+				"info": {"title": "users", "version": "1"},
+				"paths": {"/users": {"get": {}}},
+				"components": {"schemas": {"User": {"type": "object"}}}
+			}`,
+		},
+		{
+			name:  "swagger 2",
+			specs: specs("greeter.json", "farewell.json"),
+			want: `{
+				"swagger": "2.0",
+				"info": {"title": "merged", "version": "1"},
+				"tags": [{"name": "Greeter"}, {"name": "Farewell"}],
+				"paths": {
+					"/v1/hello": {"post": {"operationId": "SayHello"}},
+					"/v1/bye": {"post": {"operationId": "SayBye"}}
+				},
+				"definitions": {
+					"rpcStatus": {"type": "object"},
+					"HelloRequest": {"type": "object"},
+					"ByeRequest": {"type": "object"}
+				}
+			}`,
+		},
+		{
+			name:  "openapi 3 components",
+			specs: specs("users.json", "groups.json"),
+			want: `{
+				"openapi": "3.0.3",
+				"info": {"title": "merged", "version": "1"},
+				"paths": {"/users": {"get": {}}, "/groups": {"get": {}}},
+				"components": {
+					"schemas": {"User": {"type": "object"}, "Group": {"type": "object"}},
+					"securitySchemes": {"bearer": {"type": "http"}}
+				}
+			}`,
+		},
+		{
+			name:    "no specs",
+			wantErr: "no spec registered",
+		},
+		{
+			name:    "conflicting path",
+			specs:   specs("greeter.json", "conflict.json"),
+			wantErr: "conflict.json: conflicting paths entry '/v1/hello'",
+		},
+		{
+			name:    "different major versions",
+			specs:   specs("greeter.json", "users.json"),
+			wantErr: "cannot merge openapi v3 spec 'users.json' into v2 spec",
+		},
+		{
+			name:    "invalid json",
+			specs:   specs("invalid.json"),
+			wantErr: "could not parse openapi spec 'invalid.json'",
+		},
+		{
+			name:    "not an openapi document",
+			specs:   specs("notopenapi.json"),
+			wantErr: "'notopenapi.json' is not an openapi document",
+		},
+		{
+			name:    "missing file",
+			specs:   specs("missing.json"),
+			wantErr: "missing.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeOpenAPISpecs("merged", tt.specs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var gotDoc, wantDoc map[string]any
+			if err := json.Unmarshal(got, &gotDoc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantDoc); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotDoc, wantDoc) {
+				t.Errorf("got %s", got)
+			}
+		})
+	}
+}
+
+func TestRegisterOpenAPIUI(t *testing.T) {
+	spec := fstest.MapFS{"greeter.json": {Data: []byte(`{"swagger": "2.0", "paths": {}}`)}}
+	assets := fstest.MapFS{"swagger-ui-bundle.js": {Data: []byte("bundle")}}
+
+	tests := []struct {
+		name      string
+		configure func(s *boilerplate)
+		wantErr   string
+		wantLinks []string
+		wantFiles []string
+	}{
+		{
+			name:      "swagger with the default config",
+			configure: func(s *boilerplate) { s.WithOpenAPIUI("swagger") },
+			wantLinks: []string{DEFAULT_SWAGGER_ASSETS + "/swagger-ui-bundle.js", DEFAULT_SWAGGER_ASSETS + "/swagger-ui.css"},
+		},
+		{
+			name:      "redoc with the default config",
+			configure: func(s *boilerplate) { s.WithOpenAPIUI("redoc") },
+			wantLinks: []string{DEFAULT_REDOC_ASSETS + "/redoc.standalone.js"},
+		},
+		{
+			name: "assets url",
+			configure: func(s *boilerplate) {
+				s.WithOpenAPIUI("redoc").set(func(c *BoilerplateConfig) {
+					c.Gateway.OpenAPI.CDN = false
+					c.Gateway.OpenAPI.AssetsURL = "https://assets.example.com/redoc/"
+				})
+			},
+			wantLinks: []string{"https://assets.example.com/redoc/redoc.standalone.js"},
+		},
+		{
+			name:      "registered assets",
+			configure: func(s *boilerplate) { s.WithOpenAPIUI("swagger").WithOpenAPIAssets(assets) },
+			wantLinks: []string{"/docs/assets/swagger-ui-bundle.js"},
+			wantFiles: []string{"/docs/assets/swagger-ui-bundle.js"},
+		},
+		{
+			name: "no bundle without the cdn",
+			configure: func(s *boilerplate) {
+				s.WithOpenAPIUI("swagger").set(func(c *BoilerplateConfig) {
+					c.Gateway.OpenAPI.CDN = false
+				})
+			},
+			wantErr: "gateway.openapi.cdn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Default().(*boilerplate)
+			s.AddOpenAPISpec(spec, "greeter.json")
+			tt.configure(s)
+			conf := s.Config()
+
+			err := conf.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				if err := s.registerOpenAPI(http.NewServeMux(), conf); err == nil {
+					t.Error("registered the ui without a bundle")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			mux := http.NewServeMux()
+			if err := s.registerOpenAPI(mux, conf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			body := httpGet(t, server.URL+DEFAULT_OPENAPI_UI_PATH)
+			for _, link := range tt.wantLinks {
+				if !strings.Contains(body, `"`+link+`"`) {
+					t.Errorf("page does not link %s:\n%s", link, body)
+				}
+			}
+			for _, file := range tt.wantFiles {
+				httpGet(t, server.URL+file)
+			}
+		})
+	}
+}
+
+func httpGet(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: got status %d", url, resp.StatusCode)
+	}
+	return string(body)
+}
@@ -3,10 +3,13 @@ package boilerplate
 import (
 	"context"
 	"errors"
-	"time"
+	"log/slog"
+	"net/http"
 
-	"github.com/sirupsen/logrus"
-	"go.opentelemetry.io/contrib/bridges/otellogrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	otelruntime "go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
@@ -14,125 +17,153 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	otellog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
-	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc/credentials"
 )
 
-func setupOtel(ctx context.Context, conf OtelConfig, serviceName string) (shutdown func(context.Context) error, err error) {
-
-	var shutdownFuncs []func(context.Context) error
+// telemetry holds the parts of the otel setup that outlive setupOtel
+type telemetry struct {
+	shutdownFuncs []func(context.Context) error
+	sampler       *dynamicSampler
+	// only set if metrics are scraped by prometheus
+	metricsHandler http.Handler
+	// only set if logs are exported
+	loggerProvider otellog.LoggerProvider
+}
 
-	shutdown = func(ctx context.Context) error {
-		var err error
-		for _, fn := range shutdownFuncs {
-			err = errors.Join(err, fn(ctx))
-		}
-		shutdownFuncs = nil
-		return err
+func (t *telemetry) shutdown(ctx context.Context) error {
+	var err error
+	for _, fn := range t.shutdownFuncs {
+		err = errors.Join(err, fn(ctx))
 	}
+	t.shutdownFuncs = nil
+	return err
+}
+
+func setupOtel(ctx context.Context, conf OtelConfig, serviceName string, logger *slog.Logger) (tel *telemetry, err error) {
+
+	tel = &telemetry{}
 
 	handleErr := func(inErr error) {
-		err = errors.Join(inErr, shutdown(ctx))
+		err = errors.Join(inErr, tel.shutdown(ctx))
 	}
 
-	prop := propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	)
-	otel.SetTextMapPropagator(prop)
+	res, err := newResource(ctx, conf, serviceName, logger)
+	if err != nil {
+		return
+	}
+
+	otel.SetTextMapPropagator(newPropagator(conf.propagatorNames(), logger))
 
-	if conf.Metrics.Enabled {
+	if conf.MetricsEnabled() {
 		var meterProvider *sdkmetric.MeterProvider
-		meterProvider, err = newMeterProvider(ctx, conf, serviceName)
+		meterProvider, tel.metricsHandler, err = newMeterProvider(ctx, conf, logger, res)
 		if err != nil {
 			handleErr(err)
 			return
 		}
-		shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
-		otel.SetMeterProvider(meterProvider)
+		tel.shutdownFuncs = append(tel.shutdownFuncs, meterProvider.Shutdown)
+		mp := newCardinalityMeterProvider(meterProvider, conf.Views)
+		otel.SetMeterProvider(mp)
+
+		if err = startBuiltinMetrics(mp, conf); err != nil {
+			handleErr(err)
+			return
+		}
 	}
 
-	if conf.Tracing.Enabled {
+	if conf.TracingEnabled() {
+		tel.sampler = newDynamicSampler(conf.Sampler)
 		var tracerProvider *sdktrace.TracerProvider
-		tracerProvider, err = newTraceProvider(ctx, conf, serviceName)
+		tracerProvider, err = newTraceProvider(ctx, conf, logger, res, tel.sampler)
 		if err != nil {
 			handleErr(err)
 			return
 		}
-		shutdownFuncs = append(shutdownFuncs, tracerProvider.Shutdown)
+		tel.shutdownFuncs = append(tel.shutdownFuncs, tracerProvider.Shutdown)
 		otel.SetTracerProvider(tracerProvider)
 	}
 
-	if conf.Logging.Enabled {
+	if conf.LoggingEnabled() {
 		var loggerProvider *sdklog.LoggerProvider
-		loggerProvider, err = newLoggerProvider(ctx, conf, serviceName)
+		loggerProvider, err = newLoggerProvider(ctx, conf, logger, res)
 		if err != nil {
 			handleErr(err)
 			return
 		}
-		shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
+		tel.shutdownFuncs = append(tel.shutdownFuncs, loggerProvider.Shutdown)
 		global.SetLoggerProvider(loggerProvider)
-		hook := otellogrus.NewHook(conf.LoggerName, otellogrus.WithLoggerProvider(loggerProvider))
-		logrus.AddHook(hook)
+		tel.loggerProvider = loggerProvider
 	}
 
 	return
 }
 
-func defaultResource(serviceName string) (*resource.Resource, error) {
-	return resource.Merge(resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			// TODO: inject the service version
-			// semconv.ServiceVersion(serviceVersion)
-		),
-	)
-}
-
-func newTraceProvider(ctx context.Context, conf OtelConfig, serviceName string) (*sdktrace.TracerProvider, error) {
-	traceExporter, err := newTraceExporter(ctx, conf)
+func newTraceProvider(ctx context.Context, conf OtelConfig, logger *slog.Logger, res *resource.Resource, sampler sdktrace.Sampler) (*sdktrace.TracerProvider, error) {
+	traceExporter, err := newTraceExporter(ctx, conf, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	resource, err := defaultResource(serviceName)
-	if err != nil {
-		return nil, err
-	}
+	// errorSpanProcessor only affects unsampled spans recorded because of
+	// sampler.keepErrors, it is always installed so the option can be reloaded
+	processor := errorSpanProcessor{sdktrace.NewBatchSpanProcessor(traceExporter,
+		sdktrace.WithBatchTimeout(conf.TracingInterval()))}
 
 	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(resource),
-		sdktrace.WithBatcher(traceExporter,
-			sdktrace.WithBatchTimeout(
-				time.Duration(conf.Interval)*time.Second)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(processor),
 	)
 	return traceProvider, nil
 }
 
-func newTraceExporter(ctx context.Context, conf OtelConfig) (sdktrace.SpanExporter, error) {
+func newTraceExporter(ctx context.Context, conf OtelConfig, logger *slog.Logger) (sdktrace.SpanExporter, error) {
 	var exporter sdktrace.SpanExporter
 	var err error
 
+	settings, err := newOtlpSettings(conf, SIGNAL_TRACES, conf.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
 	switch conf.TracingProtocol() {
 
 	case "http", "https":
 		var options []otlptracehttp.Option
-
 		options = append(options, otlptracehttp.WithEndpoint(conf.TracingAddr()))
-
 		if conf.TracingInsecure() {
 			options = append(options, otlptracehttp.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlptracehttp.WithTLSClientConfig(settings.tlsConfig))
+		}
+		if settings.headers != nil {
+			options = append(options, otlptracehttp.WithHeaders(settings.headers))
+		}
+		switch settings.Compression {
+		case "gzip":
+			options = append(options, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		case "none":
+			options = append(options, otlptracehttp.WithCompression(otlptracehttp.NoCompression))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlptracehttp.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlptracehttp.WithRetry(otlptracehttp.RetryConfig(settings.retry())))
+		}
+		if settings.URLPath != "" {
+			options = append(options, otlptracehttp.WithURLPath(settings.URLPath))
 		}
-
 		exporter, err = otlptracehttp.New(ctx, options...)
 
 	case "grpc":
@@ -140,6 +171,20 @@ func newTraceExporter(ctx context.Context, conf OtelConfig) (sdktrace.SpanExport
 		options = append(options, otlptracegrpc.WithEndpoint(conf.TracingAddr()))
 		if conf.TracingInsecure() {
 			options = append(options, otlptracegrpc.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(settings.tlsConfig)))
+		}
+		if settings.headers != nil {
+			options = append(options, otlptracegrpc.WithHeaders(settings.headers))
+		}
+		if settings.gzip() {
+			options = append(options, otlptracegrpc.WithCompressor("gzip"))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlptracegrpc.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(settings.retry())))
 		}
 		exporter, err = otlptracegrpc.New(ctx, options...)
 
@@ -147,34 +192,66 @@ func newTraceExporter(ctx context.Context, conf OtelConfig) (sdktrace.SpanExport
 		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
 	}
 
-	logrus.WithContext(ctx).Debugf("otlp tracing exporter: %s, %s", conf.TracingProtocol(), conf.TracingAddr())
+	logger.DebugContext(ctx, "otlp tracing exporter", "protocol", conf.TracingProtocol(), "addr", conf.TracingAddr())
 	return exporter, err
 }
 
-func newMeterProvider(ctx context.Context, conf OtelConfig, serviceName string) (*sdkmetric.MeterProvider, error) {
-	exporter, err := newMetricExporter(ctx, conf)
-	if err != nil {
-		return nil, err
+func newMeterProvider(ctx context.Context, conf OtelConfig, logger *slog.Logger, res *resource.Resource) (*sdkmetric.MeterProvider, http.Handler, error) {
+	var reader sdkmetric.Reader
+	var handler http.Handler
+
+	// the scheduler latency is a histogram of the runtime, read on collection
+	var producers []sdkmetric.Producer
+	if conf.Instrumentation.Runtime {
+		producers = append(producers, otelruntime.NewProducer())
 	}
 
-	resource, err := defaultResource(serviceName)
-	if err != nil {
-		return nil, err
+	if conf.MetricsProtocol() == "prometheus" {
+		// a dedicated registry keeps the go client's default collectors and
+		// anything registered globally by dependencies out of the output
+		registry := prometheus.NewRegistry()
+		options := []otelprometheus.Option{otelprometheus.WithRegisterer(registry)}
+		for _, producer := range producers {
+			options = append(options, otelprometheus.WithProducer(producer))
+		}
+		exporter, err := otelprometheus.New(options...)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader = exporter
+		// exemplars are only part of the openmetrics format
+		handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+		logger.DebugContext(ctx, "prometheus metrics exporter", "path", conf.Prometheus.path())
+	} else {
+		exporter, err := newMetricExporter(ctx, conf, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		options := []sdkmetric.PeriodicReaderOption{sdkmetric.WithInterval(conf.MetricsInterval())}
+		for _, producer := range producers {
+			options = append(options, sdkmetric.WithProducer(producer))
+		}
+		reader = sdkmetric.NewPeriodicReader(exporter, options...)
 	}
 
 	meterProvider := sdkmetric.NewMeterProvider(
-		sdkmetric.WithResource(resource),
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
-			sdkmetric.WithInterval(time.Duration(conf.Interval)*time.Second))),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(newViews(conf)...),
 	)
 
-	return meterProvider, nil
+	return meterProvider, handler, nil
 }
 
-func newMetricExporter(ctx context.Context, conf OtelConfig) (sdkmetric.Exporter, error) {
+func newMetricExporter(ctx context.Context, conf OtelConfig, logger *slog.Logger) (sdkmetric.Exporter, error) {
 	var exporter sdkmetric.Exporter
 	var err error
 
+	settings, err := newOtlpSettings(conf, SIGNAL_METRICS, conf.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
 	switch conf.MetricsProtocol() {
 
 	case "http", "https":
@@ -182,6 +259,26 @@ func newMetricExporter(ctx context.Context, conf OtelConfig) (sdkmetric.Exporter
 		options = append(options, otlpmetrichttp.WithEndpoint(conf.MetricsAddr()))
 		if conf.MetricsInsecure() {
 			options = append(options, otlpmetrichttp.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlpmetrichttp.WithTLSClientConfig(settings.tlsConfig))
+		}
+		if settings.headers != nil {
+			options = append(options, otlpmetrichttp.WithHeaders(settings.headers))
+		}
+		switch settings.Compression {
+		case "gzip":
+			options = append(options, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		case "none":
+			options = append(options, otlpmetrichttp.WithCompression(otlpmetrichttp.NoCompression))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlpmetrichttp.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(settings.retry())))
+		}
+		if settings.URLPath != "" {
+			options = append(options, otlpmetrichttp.WithURLPath(settings.URLPath))
 		}
 		exporter, err = otlpmetrichttp.New(ctx, options...)
 
@@ -190,6 +287,20 @@ func newMetricExporter(ctx context.Context, conf OtelConfig) (sdkmetric.Exporter
 		options = append(options, otlpmetricgrpc.WithEndpoint(conf.MetricsAddr()))
 		if conf.MetricsInsecure() {
 			options = append(options, otlpmetricgrpc.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(settings.tlsConfig)))
+		}
+		if settings.headers != nil {
+			options = append(options, otlpmetricgrpc.WithHeaders(settings.headers))
+		}
+		if settings.gzip() {
+			options = append(options, otlpmetricgrpc.WithCompressor("gzip"))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlpmetricgrpc.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(settings.retry())))
 		}
 		exporter, err = otlpmetricgrpc.New(ctx, options...)
 
@@ -197,33 +308,33 @@ func newMetricExporter(ctx context.Context, conf OtelConfig) (sdkmetric.Exporter
 		exporter, err = stdoutmetric.New()
 	}
 
-	logrus.WithContext(ctx).Debugf("otlp metrics exporter: %s, %s", conf.MetricsProtocol(), conf.MetricsAddr())
+	logger.DebugContext(ctx, "otlp metrics exporter", "protocol", conf.MetricsProtocol(), "addr", conf.MetricsAddr())
 	return exporter, err
 }
 
-func newLoggerProvider(ctx context.Context, conf OtelConfig, serviceName string) (*sdklog.LoggerProvider, error) {
-	exporter, err := newLoggingExporter(ctx, conf)
-	if err != nil {
-		return nil, err
-	}
-
-	resource, err := defaultResource(serviceName)
+func newLoggerProvider(ctx context.Context, conf OtelConfig, logger *slog.Logger, res *resource.Resource) (*sdklog.LoggerProvider, error) {
+	exporter, err := newLoggingExporter(ctx, conf, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	processor := sdklog.NewBatchProcessor(exporter, sdklog.WithExportInterval(conf.LoggingInterval()))
 	loggingProvider := sdklog.NewLoggerProvider(
-		sdklog.WithResource(resource),
+		sdklog.WithResource(res),
 		sdklog.WithProcessor(processor),
 	)
 	return loggingProvider, nil
 }
 
-func newLoggingExporter(ctx context.Context, conf OtelConfig) (sdklog.Exporter, error) {
+func newLoggingExporter(ctx context.Context, conf OtelConfig, logger *slog.Logger) (sdklog.Exporter, error) {
 	var exporter sdklog.Exporter
 	var err error
 
+	settings, err := newOtlpSettings(conf, SIGNAL_LOGS, conf.Logging)
+	if err != nil {
+		return nil, err
+	}
+
 	switch conf.LoggingProtocol() {
 
 	case "http", "https":
@@ -231,6 +342,26 @@ func newLoggingExporter(ctx context.Context, conf OtelConfig) (sdklog.Exporter,
 		options = append(options, otlploghttp.WithEndpoint(conf.LoggingAddr()))
 		if conf.LoggingInsecure() {
 			options = append(options, otlploghttp.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlploghttp.WithTLSClientConfig(settings.tlsConfig))
+		}
+		if settings.headers != nil {
+			options = append(options, otlploghttp.WithHeaders(settings.headers))
+		}
+		switch settings.Compression {
+		case "gzip":
+			options = append(options, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		case "none":
+			options = append(options, otlploghttp.WithCompression(otlploghttp.NoCompression))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlploghttp.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlploghttp.WithRetry(otlploghttp.RetryConfig(settings.retry())))
+		}
+		if settings.URLPath != "" {
+			options = append(options, otlploghttp.WithURLPath(settings.URLPath))
 		}
 		exporter, err = otlploghttp.New(ctx, options...)
 
@@ -239,6 +370,20 @@ func newLoggingExporter(ctx context.Context, conf OtelConfig) (sdklog.Exporter,
 		options = append(options, otlploggrpc.WithEndpoint(conf.LoggingAddr()))
 		if conf.LoggingInsecure() {
 			options = append(options, otlploggrpc.WithInsecure())
+		} else if settings.tlsConfig != nil {
+			options = append(options, otlploggrpc.WithTLSCredentials(credentials.NewTLS(settings.tlsConfig)))
+		}
+		if settings.headers != nil {
+			options = append(options, otlploggrpc.WithHeaders(settings.headers))
+		}
+		if settings.gzip() {
+			options = append(options, otlploggrpc.WithCompressor("gzip"))
+		}
+		if settings.Timeout > 0 {
+			options = append(options, otlploggrpc.WithTimeout(settings.Timeout))
+		}
+		if settings.retrySet() {
+			options = append(options, otlploggrpc.WithRetry(otlploggrpc.RetryConfig(settings.retry())))
 		}
 		exporter, err = otlploggrpc.New(ctx, options...)
 
@@ -246,6 +391,6 @@ func newLoggingExporter(ctx context.Context, conf OtelConfig) (sdklog.Exporter,
 		exporter, err = stdoutlog.New()
 	}
 
-	logrus.WithContext(ctx).Debugf("otlp logger exporter: %s, %s", conf.LoggingProtocol(), conf.LoggingAddr())
+	logger.DebugContext(ctx, "otlp logger exporter", "protocol", conf.LoggingProtocol(), "addr", conf.LoggingAddr())
 	return exporter, err
 }
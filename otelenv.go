@@ -0,0 +1,131 @@
+package boilerplate
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fallbacks for the standard OTEL_* environment variables, see
+// https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/
+// Explicit configuration always takes precedence over the environment.
+
+const (
+	SIGNAL_TRACES  = "TRACES"
+	SIGNAL_METRICS = "METRICS"
+	SIGNAL_LOGS    = "LOGS"
+)
+
+// otlpURLPaths are the default paths of the http exporters
+var otlpURLPaths = map[string]string{
+	SIGNAL_TRACES:  "/v1/traces",
+	SIGNAL_METRICS: "/v1/metrics",
+	SIGNAL_LOGS:    "/v1/logs",
+}
+
+// otlpEnv looks up OTEL_EXPORTER_OTLP_<SIGNAL>_<KEY>, falling back to the
+// signal agnostic OTEL_EXPORTER_OTLP_<KEY>
+func otlpEnv(signal, key string) string {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_" + key); v != "" {
+		return v
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_" + key)
+}
+
+type otlpEndpoint struct {
+	addr string
+	// url path of the http exporters, empty for the default
+	urlPath  string
+	insecure bool
+}
+
+// otlpEnvEndpoint parses the configured endpoint url. The signal specific
+// url is used as is, the signal path is appended to the general one.
+func otlpEnvEndpoint(signal string) otlpEndpoint {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_ENDPOINT")
+	general := endpoint == ""
+	if general {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return otlpEndpoint{}
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		// not a url, assume host:port
+		return otlpEndpoint{addr: endpoint}
+	}
+
+	e := otlpEndpoint{addr: u.Host, insecure: u.Scheme == "http"}
+	if p := strings.TrimSuffix(u.Path, "/"); p != "" {
+		e.urlPath = p
+		if general {
+			e.urlPath = path.Join(p, otlpURLPaths[signal])
+		}
+	}
+	return e
+}
+
+func otlpEnvProtocol(signal string) string {
+	// console and prometheus are not otlp protocols, but selected through the
+	// exporter
+	switch exporterEnv(signal) {
+	case "console":
+		return "stdout"
+	case "prometheus":
+		if signal == SIGNAL_METRICS {
+			return "prometheus"
+		}
+	}
+
+	switch protocol := otlpEnv(signal, "PROTOCOL"); protocol {
+	case "grpc":
+		return "grpc"
+	case "http/protobuf", "http/json":
+		return "http"
+	default:
+		return ""
+	}
+}
+
+func otlpEnvInsecure(signal string) bool {
+	if insecure, err := strconv.ParseBool(otlpEnv(signal, "INSECURE")); err == nil && insecure {
+		return true
+	}
+	return otlpEnvEndpoint(signal).insecure
+}
+
+// exporterEnv returns the value of OTEL_TRACES_EXPORTER, OTEL_METRICS_EXPORTER
+// or OTEL_LOGS_EXPORTER
+func exporterEnv(signal string) string {
+	return strings.ToLower(os.Getenv("OTEL_" + signal + "_EXPORTER"))
+}
+
+func signalDisabledByEnv(signal string) bool {
+	if disabled, err := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); err == nil && disabled {
+		return true
+	}
+	return exporterEnv(signal) == "none"
+}
+
+func intervalEnv(signal string) time.Duration {
+	var key string
+	switch signal {
+	case SIGNAL_TRACES:
+		key = "OTEL_BSP_SCHEDULE_DELAY"
+	case SIGNAL_METRICS:
+		key = "OTEL_METRIC_EXPORT_INTERVAL"
+	case SIGNAL_LOGS:
+		key = "OTEL_BLRP_SCHEDULE_DELAY"
+	}
+
+	ms, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,442 @@
+package boilerplate
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ENV_PREFIX  = "BOILERPLATE"
+	ENV_CONFIG  = ENV_PREFIX + "_CONFIG"
+	REDACTED    = "[REDACTED]"
+	FLAG_CONFIG = "config"
+	FLAG_PRINT  = "print-config"
+)
+
+// ErrConfigPrinted is returned by ParseConfig after the configuration was
+// printed because of the -print-config flag. Callers should exit.
+var ErrConfigPrinted = errors.New("configuration printed")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configField is a settable leaf of BoilerplateConfig, addressed by the yaml
+// names of the fields leading to it
+type configField struct {
+	path   []string
+	value  reflect.Value
+	redact bool
+	// scalar fields can be set from a single env var or flag
+	scalar bool
+}
+
+func (f configField) key() string {
+	return strings.Join(f.path, ".")
+}
+
+func (f configField) env() string {
+	parts := []string{ENV_PREFIX}
+	for _, p := range f.path {
+		parts = append(parts, strings.ToUpper(strings.Join(splitCamel(p), "_")))
+	}
+	return strings.Join(parts, "_")
+}
+
+// error reports a value that could not be set, without the value of secrets
+func (f configField) error(name string, err error) error {
+	if f.redact {
+		return fmt.Errorf("%s: invalid value", name)
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+func (f configField) flag() string {
+	var parts []string
+	for _, p := range f.path {
+		parts = append(parts, strings.ToLower(strings.Join(splitCamel(p), "-")))
+	}
+	return strings.Join(parts, "-")
+}
+
+func configFields(conf *BoilerplateConfig) []configField {
+	var fields []configField
+	walkConfig(reflect.ValueOf(conf).Elem(), nil, false, &fields)
+	return fields
+}
+
+func configFieldsByKey(conf *BoilerplateConfig) map[string]configField {
+	fields := map[string]configField{}
+	for _, field := range configFields(conf) {
+		fields[field.key()] = field
+	}
+	return fields
+}
+
+func walkConfig(v reflect.Value, path []string, redact bool, fields *[]configField) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, inline := yamlName(sf)
+		if name == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !inline {
+			fieldPath = append(append([]string{}, path...), name)
+		}
+		fieldRedact := redact || sf.Tag.Get("redact") == "true"
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			walkConfig(fv, fieldPath, fieldRedact, fields)
+			continue
+		}
+
+		*fields = append(*fields, configField{
+			path:   fieldPath,
+			value:  fv,
+			redact: fieldRedact,
+			scalar: isScalarField(fv.Type()),
+		})
+	}
+}
+
+func yamlName(sf reflect.StructField) (name string, inline bool) {
+	tag := sf.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name, false
+}
+
+// isScalarField reports whether a field can be expressed as a single
+// environment variable or flag
+func isScalarField(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return isScalarField(t.Elem()) && t.Elem().Kind() != reflect.Slice
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setField parses s into v. Lists are comma separated, maps are written as
+// comma separated key=value pairs.
+func setField(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitList(s) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got '%s'", item)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(value)))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatField(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		var items []string
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatField(v.Index(i)))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		var items []string
+		for _, key := range v.MapKeys() {
+			items = append(items, key.String()+"="+v.MapIndex(key).String())
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitCamel splits "maxRecvMsgSize" into [max Recv Msg Size]
+func splitCamel(s string) []string {
+	var words []string
+	start := 0
+	runes := []rune(s)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// ApplyEnv overrides the configuration with BOILERPLATE_* environment
+// variables, e.g. BOILERPLATE_GRPC_ADDR or BOILERPLATE_OTEL_TRACING_PROTOCOL.
+func (c *BoilerplateConfig) ApplyEnv() error {
+	var errs []error
+	for _, field := range configFields(c) {
+		if !field.scalar {
+			continue
+		}
+		value, ok := os.LookupEnv(field.env())
+		if !ok {
+			continue
+		}
+		if err := setField(field.value, value); err != nil {
+			errs = append(errs, field.error(field.env(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configSource remembers the layers a config was built from, so a reload
+// rebuilds it the same way
+type configSource struct {
+	path string
+	// raw flag values by field key
+	flags map[string]string
+}
+
+// load layers the file, the environment and the flags over the defaults
+func (src *configSource) load() (BoilerplateConfig, error) {
+	conf := defaultConfig
+	if src.path != "" {
+		var err error
+		if conf, err = LoadConfig(src.path); err != nil {
+			return conf, err
+		}
+	}
+	if err := conf.ApplyEnv(); err != nil {
+		return conf, err
+	}
+	if err := conf.applyFlags(src.flags); err != nil {
+		return conf, err
+	}
+	conf.source = src
+	return conf, nil
+}
+
+func (c *BoilerplateConfig) applyFlags(flags map[string]string) error {
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := configFieldsByKey(c)
+
+	var errs []error
+	for _, key := range keys {
+		field := fields[key]
+		if err := setField(field.value, flags[key]); err != nil {
+			errs = append(errs, field.error("-"+field.flag(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configFlag defers setting the field until the config file and the
+// environment have been applied, so flags always take precedence
+type configFlag struct {
+	field configField
+	raw   string
+	set   bool
+}
+
+func (f *configFlag) String() string {
+	if f.set {
+		return f.raw
+	}
+	if !f.field.value.IsValid() {
+		return ""
+	}
+	return formatField(f.field.value)
+}
+
+func (f *configFlag) Set(s string) error {
+	// parse into a scratch value to report errors during flag parsing. The
+	// flag package prints the value, so secrets are left to applyFlags.
+	if !f.field.redact {
+		scratch := reflect.New(f.field.value.Type()).Elem()
+		if err := setField(scratch, s); err != nil {
+			return err
+		}
+	}
+	f.raw = s
+	f.set = true
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.field.value.Kind() == reflect.Bool
+}
+
+// ParseConfig builds the configuration from the defaults, an optional config
+// file (-config or BOILERPLATE_CONFIG), BOILERPLATE_* environment variables
+// and command line flags, in ascending order of precedence. Every config field
+// gets a flag, e.g. -grpc-addr or -otel-tracing-protocol. pflag users can
+// pass a flag set added via pflag.CommandLine.AddGoFlagSet.
+func ParseConfig(fs *flag.FlagSet, args []string, extension any) (BoilerplateConfig, error) {
+	configPath := fs.String(FLAG_CONFIG, os.Getenv(ENV_CONFIG), "path to a yaml, json or toml config file")
+	printConfig := fs.Bool(FLAG_PRINT, false, "print the effective configuration with secrets redacted and exit")
+
+	defaults := defaultConfig
+	for _, field := range configFields(&defaults) {
+		if !field.scalar {
+			continue
+		}
+		usage := fmt.Sprintf("sets %s (env %s)", field.key(), field.env())
+		fs.Var(&configFlag{field: field}, field.flag(), usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return defaultConfig, err
+	}
+
+	conf := defaultConfig
+	if *configPath != "" {
+		var err error
+		if conf, err = LoadConfigInto(*configPath, extension); err != nil {
+			return conf, err
+		}
+	}
+
+	if err := conf.ApplyEnv(); err != nil {
+		return conf, err
+	}
+
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		if cf, ok := f.Value.(*configFlag); ok {
+			flags[cf.field.key()] = cf.raw
+		}
+	})
+	if err := conf.applyFlags(flags); err != nil {
+		return conf, err
+	}
+	conf.source = &configSource{path: *configPath, flags: flags}
+
+	if *printConfig {
+		if err := PrintConfig(fs.Output(), conf); err != nil {
+			return conf, err
+		}
+		return conf, ErrConfigPrinted
+	}
+
+	return conf, nil
+}
+
+// PrintConfig writes the configuration as yaml, with secrets redacted
+func PrintConfig(w io.Writer, conf BoilerplateConfig) error {
+	for _, field := range configFields(&conf) {
+		if !field.redact || field.value.IsZero() {
+			continue
+		}
+
+		switch field.value.Kind() {
+		case reflect.String:
+			field.value.SetString(REDACTED)
+		case reflect.Map:
+			redacted := reflect.MakeMap(field.value.Type())
+			for _, key := range field.value.MapKeys() {
+				redacted.SetMapIndex(key, reflect.ValueOf(REDACTED))
+			}
+			field.value.Set(redacted)
+		default:
+			field.value.Set(reflect.Zero(field.value.Type()))
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(conf); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,280 @@
+package boilerplate
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFieldNames(t *testing.T) {
+	tests := []struct {
+		key  string
+		env  string
+		flag string
+	}{
+		{"grpc.addr", "BOILERPLATE_GRPC_ADDR", "grpc-addr"},
+		{"grpc.maxRecvMsgSize", "BOILERPLATE_GRPC_MAX_RECV_MSG_SIZE", "grpc-max-recv-msg-size"},
+		{"otel.tracing.protocol", "BOILERPLATE_OTEL_TRACING_PROTOCOL", "otel-tracing-protocol"},
+		{"otel.headers", "BOILERPLATE_OTEL_HEADERS", "otel-headers"},
+	}
+
+	conf := defaultConfig
+	fields := configFieldsByKey(&conf)
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			field, ok := fields[tt.key]
+			if !ok {
+				t.Fatalf("no field %s", tt.key)
+			}
+			if field.env() != tt.env || field.flag() != tt.flag {
+				t.Errorf("got env %s, flag %s, want %s, %s", field.env(), field.flag(), tt.env, tt.flag)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, conf BoilerplateConfig)
+		wantErr string
+	}{
+		{
+			name: "scalars",
+			env: map[string]string{
+				"BOILERPLATE_GRPC_ADDR":                   ":6000",
+				"BOILERPLATE_GRPC_MAX_RECV_MSG_SIZE":      "1024",
+				"BOILERPLATE_GRPC_REFLECTION_ENABLED":     "true",
+				"BOILERPLATE_GATEWAY_REQUEST_TIMEOUT":     "3s",
+				"BOILERPLATE_LOG_ACCESS_SUCCESS_RATIO":    "0.5",
+				"BOILERPLATE_OTEL_TRACING_PROTOCOL":       "http",
+				"BOILERPLATE_GRPC_MAX_CONCURRENT_STREAMS": "10",
+			},
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.Grpc.Addr != ":6000" || conf.Grpc.MaxRecvMsgSize != 1024 || !conf.Grpc.Reflection.Enabled ||
+					conf.Gateway.RequestTimeout != 3*time.Second || conf.Log.Access.SuccessRatio != 0.5 ||
+					conf.Otel.Tracing.Protocol != "http" || conf.Grpc.MaxConcurrentStreams != 10 {
+					t.Errorf("env not applied: %+v", conf)
+				}
+			},
+		},
+		{
+			name: "lists and maps",
+			env: map[string]string{
+				"BOILERPLATE_GATEWAY_ALLOWED_ORIGINS": "https://a.example, https://b.example",
+				"BOILERPLATE_OTEL_HEADERS":            "authorization=Bearer x, x-tenant=t",
+			},
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(conf.Gateway.AllowedOrigins, want) {
+					t.Errorf("got allowed origins %v, want %v", conf.Gateway.AllowedOrigins, want)
+				}
+				if want := map[string]string{"authorization": "Bearer x", "x-tenant": "t"}; !reflect.DeepEqual(conf.Otel.Headers, want) {
+					t.Errorf("got headers %v, want %v", conf.Otel.Headers, want)
+				}
+			},
+		},
+		{
+			name:    "invalid value",
+			env:     map[string]string{"BOILERPLATE_GRPC_MAX_RECV_MSG_SIZE": "large"},
+			wantErr: `BOILERPLATE_GRPC_MAX_RECV_MSG_SIZE: strconv.ParseInt: parsing "large"`,
+		},
+		{
+			name:    "invalid secret",
+			env:     map[string]string{"BOILERPLATE_OTEL_HEADERS": "s3cr3t"},
+			wantErr: "BOILERPLATE_OTEL_HEADERS: invalid value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			conf := defaultConfig
+			err := conf.ApplyEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "s3cr3t") {
+					t.Errorf("error contains the secret: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, conf)
+		})
+	}
+}
+
+func TestParseConfigPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", `
+serviceName: from-file
+grpc:
+  addr: ":1000"
+gateway:
+  addr: ":2000"
+admin:
+  addr: ":3000"
+`)
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		wantService string
+		wantGrpc    string
+		wantGateway string
+		wantAdmin   string
+	}{
+		{
+			name:        "defaults",
+			wantService: defaultConfig.ServiceName,
+			wantGrpc:    DEFAULT_GRPC_ADDR,
+			wantGateway: DEFAULT_GATEWAY_ADDR,
+			wantAdmin:   DEFAULT_ADMIN_ADDR,
+		},
+		{
+			name:        "file over defaults",
+			args:        []string{"-config", file},
+			wantService: "from-file",
+			wantGrpc:    ":1000",
+			wantGateway: ":2000",
+			wantAdmin:   ":3000",
+		},
+		{
+			name:        "config path from env",
+			env:         map[string]string{ENV_CONFIG: file},
+			wantService: "from-file",
+			wantGrpc:    ":1000",
+			wantGateway: ":2000",
+			wantAdmin:   ":3000",
+		},
+		{
+			name:        "env over file",
+			env:         map[string]string{"BOILERPLATE_GRPC_ADDR": ":1001", "BOILERPLATE_GATEWAY_ADDR": ":2001"},
+			args:        []string{"-config", file},
+			wantService: "from-file",
+			wantGrpc:    ":1001",
+			wantGateway: ":2001",
+			wantAdmin:   ":3000",
+		},
+		{
+			name:        "flags over env",
+			env:         map[string]string{"BOILERPLATE_GRPC_ADDR": ":1001", "BOILERPLATE_GATEWAY_ADDR": ":2001"},
+			args:        []string{"-config", file, "-grpc-addr", ":1002", "-service-name", "from-flag"},
+			wantService: "from-flag",
+			wantGrpc:    ":1002",
+			wantGateway: ":2001",
+			wantAdmin:   ":3000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			conf, err := ParseConfig(fs, tt.args, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := []string{conf.ServiceName, conf.Grpc.Addr, conf.Gateway.Addr, conf.Admin.Addr}
+			want := []string{tt.wantService, tt.wantGrpc, tt.wantGateway, tt.wantAdmin}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"invalid flag value", []string{"-grpc-max-recv-msg-size", "large"}, `invalid value "large" for flag -grpc-max-recv-msg-size`},
+		{"invalid secret flag value", []string{"-otel-headers", "s3cr3t"}, "-otel-headers: invalid value"},
+		{"missing config file", []string{"-config", "/does/not/exist.yaml"}, "no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(&output)
+
+			_, err := ParseConfig(fs, tt.args, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if strings.Contains(err.Error()+output.String(), "s3cr3t") {
+				t.Errorf("error or output contains the secret: %v\n%s", err, output.String())
+			}
+		})
+	}
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	tests := []struct {
+		name  string
+		print func(w io.Writer) error
+	}{
+		{
+			name: "PrintConfig",
+			print: func(w io.Writer) error {
+				conf := defaultConfig
+				conf.Admin.Token = "s3cr3t-token"
+				conf.Otel.Tracing.Headers = map[string]string{"authorization": "s3cr3t-header"}
+				return PrintConfig(w, conf)
+			},
+		},
+		{
+			name: "ParseConfig -print-config",
+			print: func(w io.Writer) error {
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				fs.SetOutput(w)
+				_, err := ParseConfig(fs, []string{
+					"-print-config",
+					"-admin-token", "s3cr3t-token",
+					"-otel-tracing-headers", "authorization=s3cr3t-header",
+				}, nil)
+				if !errors.Is(err, ErrConfigPrinted) {
+					return err
+				}
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := tt.print(&output); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			printed := output.String()
+			if strings.Contains(printed, "s3cr3t") {
+				t.Errorf("printed config contains a secret:\n%s", printed)
+			}
+			for _, want := range []string{"token: '" + REDACTED + "'", "authorization: '" + REDACTED + "'", "addr: " + DEFAULT_GRPC_ADDR} {
+				if !strings.Contains(printed, want) {
+					t.Errorf("printed config lacks %q:\n%s", want, printed)
+				}
+			}
+		})
+	}
+}
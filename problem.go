@@ -0,0 +1,152 @@
+package boilerplate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const problemContentType = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 representation of a gRPC status error
+type ProblemDetails struct {
+	Type          string            `json:"type"`
+	Title         string            `json:"title"`
+	Status        int               `json:"status"`
+	Detail        string            `json:"detail,omitempty"`
+	Instance      string            `json:"instance,omitempty"`
+	Code          string            `json:"code,omitempty"`
+	TraceID       string            `json:"traceId,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	Domain        string            `json:"domain,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	InvalidParams []InvalidParam    `json:"invalidParams,omitempty"`
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func problemErrorHandler(conf ProblemConfig, logger *slog.Logger) runtime.ErrorHandlerFunc {
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		httpStatus := 0
+		var customStatus *runtime.HTTPStatusError
+		if errors.As(err, &customStatus) {
+			httpStatus = customStatus.HTTPStatus
+			err = customStatus.Err
+		}
+
+		s := status.Convert(err)
+		if httpStatus == 0 {
+			httpStatus = runtime.HTTPStatusFromCode(s.Code())
+		}
+
+		problem := ProblemDetails{
+			Type:     problemType(conf.TypeBaseURL, s.Code()),
+			Title:    http.StatusText(httpStatus),
+			Status:   httpStatus,
+			Detail:   s.Message(),
+			Instance: r.URL.Path,
+			Code:     s.Code().String(),
+		}
+
+		if conf.TraceID {
+			if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+				problem.TraceID = sc.TraceID().String()
+			}
+		}
+
+		w.Header().Del("Trailer")
+		w.Header().Del("Transfer-Encoding")
+
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			for k, vs := range md.HeaderMD {
+				if h, ok := outgoingHeaderMatcher(k); ok {
+					for _, v := range vs {
+						w.Header().Add(h, v)
+					}
+				}
+			}
+		}
+
+		if conf.Details {
+			for _, detail := range s.Details() {
+				switch d := detail.(type) {
+				case *errdetails.BadRequest:
+					for _, v := range d.GetFieldViolations() {
+						problem.InvalidParams = append(problem.InvalidParams, InvalidParam{
+							Name:   v.GetField(),
+							Reason: v.GetDescription(),
+						})
+					}
+				case *errdetails.ErrorInfo:
+					problem.Reason = d.GetReason()
+					problem.Domain = d.GetDomain()
+					problem.Metadata = d.GetMetadata()
+				case *errdetails.RetryInfo:
+					if delay := d.GetRetryDelay(); delay != nil {
+						seconds := int(math.Ceil(delay.AsDuration().Seconds()))
+						w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					}
+				}
+			}
+		}
+
+		if s.Code() == codes.Unauthenticated {
+			w.Header().Set("WWW-Authenticate", bearerChallenge(s))
+		}
+
+		buf, err := json.Marshal(problem)
+		if err != nil {
+			logger.ErrorContext(ctx, "could not marshal problem details", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", problemContentType)
+		w.WriteHeader(httpStatus)
+		if _, err := w.Write(buf); err != nil {
+			logger.ErrorContext(ctx, "could not write problem details", "error", err)
+		}
+	}
+}
+
+func problemType(baseURL string, code codes.Code) string {
+	if baseURL == "" {
+		return "about:blank"
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/"), strings.ToLower(code.String()))
+}
+
+// bearerChallenge follows RFC 6750, the status message is not passed on as it
+// may contain server internals
+func bearerChallenge(s *status.Status) string {
+	if s.Message() == status.Convert(errInvalidToken).Message() {
+		return `Bearer error="invalid_token"`
+	}
+	return "Bearer"
+}
+
+func outgoingHeaderMatcher(key string) (string, bool) {
+	// requestIDHandler already set the request id header
+	if key == requestIDHeader {
+		return "", false
+	}
+	if header, ok := rateLimitHeaders[key]; ok {
+		return header, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
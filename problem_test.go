@@ -0,0 +1,200 @@
+package boilerplate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestProblemErrorHandler(t *testing.T) {
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+
+	detailed, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "name", Description: "must not be empty"},
+		}},
+		&errdetails.ErrorInfo{Reason: "NAME_EMPTY", Domain: "greeter.example.com", Metadata: map[string]string{"field": "name"}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		conf        ProblemConfig
+		ctx         context.Context
+		err         error
+		wantStatus  int
+		wantProblem ProblemDetails
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "status error",
+			err:        status.Error(codes.NotFound, "no such greeting"),
+			wantStatus: http.StatusNotFound,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "no such greeting", Instance: "/v1/hello", Code: "NotFound",
+			},
+			wantHeaders: map[string]string{"Content-Type": problemContentType},
+		},
+		{
+			name:       "type base url",
+			conf:       ProblemConfig{TypeBaseURL: "https://errors.example.com/"},
+			err:        status.Error(codes.NotFound, "no such greeting"),
+			wantStatus: http.StatusNotFound,
+			wantProblem: ProblemDetails{
+				Type: "https://errors.example.com/notfound", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "no such greeting", Instance: "/v1/hello", Code: "NotFound",
+			},
+		},
+		{
+			name:       "http status of the gateway",
+			err:        &runtime.HTTPStatusError{HTTPStatus: http.StatusMethodNotAllowed, Err: status.Error(codes.Unimplemented, "method not allowed")},
+			wantStatus: http.StatusMethodNotAllowed,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Method Not Allowed", Status: http.StatusMethodNotAllowed,
+				Detail: "method not allowed", Instance: "/v1/hello", Code: "Unimplemented",
+			},
+		},
+		{
+			name:       "plain error",
+			err:        io.ErrUnexpectedEOF,
+			wantStatus: http.StatusInternalServerError,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Detail: "unexpected EOF", Instance: "/v1/hello", Code: "Unknown",
+			},
+		},
+		{
+			name:       "details",
+			conf:       ProblemConfig{Details: true},
+			err:        detailed.Err(),
+			wantStatus: http.StatusBadRequest,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "invalid request", Instance: "/v1/hello", Code: "InvalidArgument",
+				Reason: "NAME_EMPTY", Domain: "greeter.example.com", Metadata: map[string]string{"field": "name"},
+				InvalidParams: []InvalidParam{{Name: "name", Reason: "must not be empty"}},
+			},
+			wantHeaders: map[string]string{"Retry-After": "2"},
+		},
+		{
+			name:       "details are left out by default",
+			err:        detailed.Err(),
+			wantStatus: http.StatusBadRequest,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "invalid request", Instance: "/v1/hello", Code: "InvalidArgument",
+			},
+			wantHeaders: map[string]string{"Retry-After": ""},
+		},
+		{
+			name:       "trace id",
+			conf:       ProblemConfig{TraceID: true},
+			ctx:        spanCtx,
+			err:        status.Error(codes.Internal, "internal error"),
+			wantStatus: http.StatusInternalServerError,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Detail: "internal error", Instance: "/v1/hello", Code: "Internal", TraceID: traceID.String(),
+			},
+		},
+		{
+			name:       "invalid token",
+			err:        errInvalidToken,
+			wantStatus: http.StatusUnauthorized,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Unauthorized", Status: http.StatusUnauthorized,
+				Detail: "invalid token", Instance: "/v1/hello", Code: "Unauthenticated",
+			},
+			wantHeaders: map[string]string{"WWW-Authenticate": `Bearer error="invalid_token"`},
+		},
+		{
+			name: "header metadata",
+			ctx: runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+				HeaderMD: metadata.Pairs("x-greeting", "hello", "ratelimit-remaining", "0", requestIDHeader, "id"),
+			}),
+			err:        status.Error(codes.ResourceExhausted, "rate limit exceeded"),
+			wantStatus: http.StatusTooManyRequests,
+			wantProblem: ProblemDetails{
+				Type: "about:blank", Title: "Too Many Requests", Status: http.StatusTooManyRequests,
+				Detail: "rate limit exceeded", Instance: "/v1/hello", Code: "ResourceExhausted",
+			},
+			wantHeaders: map[string]string{
+				"Grpc-Metadata-X-Greeting": "hello",
+				"RateLimit-Remaining":      "0",
+				requestIDHeader:            "",
+			},
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/hello", nil)
+
+			problemErrorHandler(tt.conf, logger)(ctx, nil, nil, w, r, tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			var got ProblemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantProblem) {
+				t.Errorf("got %+v, want %+v", got, tt.wantProblem)
+			}
+			for header, want := range tt.wantHeaders {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("got header %s %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBearerChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"invalid token", errInvalidToken, `Bearer error="invalid_token"`},
+		{"other messages are not passed on", status.Error(codes.Unauthenticated, "key 1234 not found"), "Bearer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bearerChallenge(status.Convert(tt.err)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package boilerplate
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/contrib/propagators/ot"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var defaultPropagators = []string{"tracecontext", "baggage"}
+
+// propagatorHeaders lists the headers each propagator reads, a trailing *
+// matches a prefix. The gateway forwards them to the grpc server.
+var propagatorHeaders = map[string][]string{
+	"tracecontext": {"traceparent", "tracestate"},
+	"baggage":      {"baggage"},
+	"b3":           {"b3", "x-b3-traceid", "x-b3-spanid", "x-b3-parentspanid", "x-b3-sampled", "x-b3-flags"},
+	"b3multi":      {"b3", "x-b3-traceid", "x-b3-spanid", "x-b3-parentspanid", "x-b3-sampled", "x-b3-flags"},
+	"jaeger":       {"uber-trace-id", "uberctx-*"},
+	"ottrace":      {"ot-tracer-traceid", "ot-tracer-spanid", "ot-tracer-sampled", "ot-baggage-*"},
+	"xray":         {"x-amzn-trace-id"},
+	"none":         {},
+}
+
+var validPropagators = []string{"tracecontext", "baggage", "b3", "b3multi", "jaeger", "ottrace", "xray", "none"}
+
+// propagatorNames returns the configured propagators, falling back to
+// OTEL_PROPAGATORS
+func (c OtelConfig) propagatorNames() []string {
+	if len(c.Propagators) > 0 {
+		return c.Propagators
+	}
+	if env := os.Getenv("OTEL_PROPAGATORS"); env != "" {
+		return splitList(strings.ToLower(env))
+	}
+	return defaultPropagators
+}
+
+func newPropagator(names []string, logger *slog.Logger) propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		case "ottrace":
+			propagators = append(propagators, ot.OT{})
+		case "xray":
+			propagators = append(propagators, xray.Propagator{})
+		case "none":
+			return propagation.NewCompositeTextMapPropagator()
+		default:
+			logger.Warn("ignoring unknown propagator", "propagator", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// incomingHeaderMatcher forwards the headers of the configured propagators,
+// the request id and the given headers to the grpc server, in addition to the
+// grpc-gateway defaults. The headers only the gateway may set are dropped.
+func incomingHeaderMatcher(names []string, headers ...string) runtime.HeaderMatcherFunc {
+	exact := append([]string{requestIDHeader}, headers...)
+	var prefixes []string
+	for _, name := range names {
+		for _, header := range propagatorHeaders[name] {
+			if prefix, ok := strings.CutSuffix(header, "*"); ok {
+				prefixes = append(prefixes, prefix)
+			} else {
+				exact = append(exact, header)
+			}
+		}
+	}
+
+	return func(key string) (string, bool) {
+		lower := strings.ToLower(key)
+		if privateHeader(strings.TrimPrefix(lower, strings.ToLower(runtime.MetadataHeaderPrefix))) {
+			return "", false
+		}
+		if slices.Contains(exact, lower) {
+			return lower, true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(lower, prefix) {
+				return lower, true
+			}
+		}
+		return runtime.DefaultHeaderMatcher(key)
+	}
+}
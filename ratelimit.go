@@ -0,0 +1,259 @@
+package boilerplate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var validRateLimitKeys = []string{"subject", "apiKey", "peer"}
+
+// headers set on every rate limited call, the gateway forwards them without
+// the Grpc-Metadata- prefix
+var rateLimitHeaders = map[string]string{
+	"ratelimit-limit":     "RateLimit-Limit",
+	"ratelimit-remaining": "RateLimit-Remaining",
+	"ratelimit-reset":     "RateLimit-Reset",
+	"retry-after":         "Retry-After",
+}
+
+// RateLimitStore keeps the token buckets of the rate limiter. The in memory
+// store only limits a single instance, a store shared by all instances can
+// be set with WithRateLimitStore.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key, which holds up to burst
+	// tokens and refills at rate tokens per second
+	Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error)
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// until the next token is available, if not allowed
+	RetryAfter time.Duration
+	// until the bucket is full again
+	Reset time.Duration
+}
+
+// NewMemoryRateLimitStore returns the store used by default
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	rate   float64
+	burst  float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	// the limits may have changed on reload
+	b.rate, b.burst = rate, float64(burst)
+	b.refill(now)
+
+	var result RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((b.burst - b.tokens) / rate)
+	return result, nil
+}
+
+// sweep drops the buckets that refilled completely, they are recreated full
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// rateLimiter runs after the interceptors added by the service, so the jwt
+// subject is known
+type rateLimiter struct {
+	conf     atomic.Pointer[RateLimitConfig]
+	store    RateLimitStore
+	logger   *slog.Logger
+	requests metric.Int64Counter
+}
+
+func newRateLimiter(conf RateLimitConfig, store RateLimitStore, mp metric.MeterProvider, logger *slog.Logger) (*rateLimiter, error) {
+	requests, err := mp.Meter(instrumentationName).Int64Counter("boilerplate.rate_limit.requests",
+		metric.WithDescription("Number of grpc calls checked by the rate limiter, by result."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return nil, err
+	}
+
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+
+	l := &rateLimiter{store: store, logger: logger, requests: requests}
+	l.update(conf)
+	return l, nil
+}
+
+func (l *rateLimiter) update(conf RateLimitConfig) {
+	l.conf.Store(&conf)
+}
+
+// rule returns the rule of the method and the name of its bucket
+func (c RateLimitConfig) rule(fullMethod string) (RateLimitRule, string, bool) {
+	for _, rule := range c.Rules {
+		if ok, _ := path.Match(rule.Method, fullMethod); ok {
+			return rule, rule.Method, true
+		}
+	}
+	return c.Default, fullMethod, c.Default.Rate > 0
+}
+
+// take checks the limit of the call and sets the rate limit headers. The
+// returned error rejects the call.
+func (l *rateLimiter) take(ctx context.Context, fullMethod string, setHeader func(metadata.MD) error) error {
+	conf := l.conf.Load()
+	rule, bucket, ok := conf.rule(fullMethod)
+	if !ok {
+		return nil
+	}
+
+	burst := rule.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(rule.Rate))
+	}
+	key := bucket + "|" + rateLimitKey(ctx, cmp.Or(rule.Key, conf.Key, DEFAULT_RATE_LIMIT_KEY), conf.apiKeyHeader())
+
+	result, err := l.store.Take(ctx, key, rule.Rate, burst)
+	if err != nil {
+		// an unavailable store must not take down the service
+		l.logger.WarnContext(ctx, "rate limit store failed, allowing call", "error", err)
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(burst),
+		"ratelimit-remaining", strconv.Itoa(result.Remaining),
+		"ratelimit-reset", strconv.Itoa(ceilSeconds(result.Reset)),
+	)
+	if !result.Allowed {
+		md.Set("retry-after", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+	_ = setHeader(md)
+
+	outcome := "allowed"
+	if !result.Allowed {
+		outcome = "rejected"
+	}
+	attrs := append(rpcAttributes(fullMethod), attribute.String("rate_limit.result", outcome))
+	l.requests.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+	if result.Allowed {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func (l *rateLimiter) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		setHeader := func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }
+		if err := l.take(ctx, info.FullMethod, setHeader); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (l *rateLimiter) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.take(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey identifies the client of the call. Calls without a subject or
+// api key fall back to the client address.
+func rateLimitKey(ctx context.Context, kind string, apiKeyHeader string) string {
+	switch kind {
+	case "subject":
+		if claims, ok := ctx.Value(claimsKey{}).(jwt.Claims); ok {
+			if sub, err := claims.GetSubject(); err == nil && sub != "" {
+				return "subject:" + sub
+			}
+		}
+	case "apiKey":
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if keys := md.Get(apiKeyHeader); len(keys) > 0 && keys[0] != "" {
+				// the store must not learn the api keys
+				sum := sha256.Sum256([]byte(keys[0]))
+				return "apiKey:" + hex.EncodeToString(sum[:])
+			}
+		}
+	}
+	return "peer:" + clientIP(ctx)
+}
@@ -0,0 +1,193 @@
+package boilerplate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func newTestRateLimitStore(now *time.Time) *memoryRateLimitStore {
+	store := NewMemoryRateLimitStore().(*memoryRateLimitStore)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		want    RateLimitResult
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{
+			name:  "burst",
+			rate:  1,
+			burst: 3,
+			steps: []step{
+				{want: RateLimitResult{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{want: RateLimitResult{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{want: RateLimitResult{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{want: RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: time.Second, Reset: 3 * time.Second}},
+			},
+		},
+		{
+			name:  "refill",
+			rate:  2,
+			burst: 1,
+			steps: []step{
+				{want: RateLimitResult{Allowed: true, Remaining: 0, Reset: 500 * time.Millisecond}},
+				{advance: 250 * time.Millisecond, want: RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: 250 * time.Millisecond, Reset: 250 * time.Millisecond}},
+				{advance: 250 * time.Millisecond, want: RateLimitResult{Allowed: true, Remaining: 0, Reset: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name:  "refill stops at burst",
+			rate:  1,
+			burst: 2,
+			steps: []step{
+				{want: RateLimitResult{Allowed: true, Remaining: 1, Reset: time.Second}},
+				{advance: time.Hour, want: RateLimitResult{Allowed: true, Remaining: 1, Reset: time.Second}},
+			},
+		},
+		{
+			name:  "rate below one per second",
+			rate:  0.5,
+			burst: 1,
+			steps: []step{
+				{want: RateLimitResult{Allowed: true, Remaining: 0, Reset: 2 * time.Second}},
+				{advance: time.Second, want: RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: time.Second, Reset: time.Second}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			store := newTestRateLimitStore(&now)
+
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				got, err := store.Take(context.Background(), "key", tt.rate, tt.burst)
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+				if got != step.want {
+					t.Errorf("step %d: got %+v, want %+v", i, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := newTestRateLimitStore(&now)
+	ctx := context.Background()
+
+	store.Take(ctx, "full", 1, 1)
+	store.Take(ctx, "slow", 0.001, 1)
+
+	// no sweep within a minute of the last one
+	now = now.Add(30 * time.Second)
+	store.Take(ctx, "other", 1, 1)
+	if len(store.buckets) != 3 {
+		t.Fatalf("got %d buckets before the sweep, want 3", len(store.buckets))
+	}
+
+	now = now.Add(time.Minute)
+	store.Take(ctx, "other", 1, 1)
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{"full", false},
+		{"slow", true},
+		{"other", true},
+	}
+	for _, tt := range tests {
+		if _, ok := store.buckets[tt.key]; ok != tt.kept {
+			t.Errorf("bucket %q kept = %v, want %v", tt.key, ok, tt.kept)
+		}
+	}
+}
+
+func TestRateLimitConfigRule(t *testing.T) {
+	conf := RateLimitConfig{
+		Default: RateLimitRule{Rate: 10},
+		Rules: []RateLimitRule{
+			{Method: "/greeter.v1.GreeterService/SayHello", Rate: 1},
+			{Method: "/greeter.v1.GreeterService/*", Rate: 2},
+			{Method: "/greeter.v1.GreeterService/SayGoodbye", Rate: 3},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		conf       RateLimitConfig
+		method     string
+		wantRate   float64
+		wantBucket string
+		wantOk     bool
+	}{
+		{"exact rule", conf, "/greeter.v1.GreeterService/SayHello", 1, "/greeter.v1.GreeterService/SayHello", true},
+		{"first matching rule wins", conf, "/greeter.v1.GreeterService/SayGoodbye", 2, "/greeter.v1.GreeterService/*", true},
+		{"default", conf, "/other.v1.OtherService/Call", 10, "/other.v1.OtherService/Call", true},
+		{"unlimited without default", RateLimitConfig{Rules: conf.Rules}, "/other.v1.OtherService/Call", 0, "/other.v1.OtherService/Call", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, bucket, ok := tt.conf.rule(tt.method)
+			if rule.Rate != tt.wantRate || bucket != tt.wantBucket || ok != tt.wantOk {
+				t.Errorf("got rate %g, bucket %q, ok %v, want rate %g, bucket %q, ok %v",
+					rule.Rate, bucket, ok, tt.wantRate, tt.wantBucket, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	peerCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4000},
+	})
+	withClaims := context.WithValue(peerCtx, claimsKey{}, jwt.RegisteredClaims{Subject: "alice"})
+	sum := sha256.Sum256([]byte("secret"))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		kind string
+		want string
+	}{
+		{"subject", withClaims, "subject", "subject:alice"},
+		{"subject falls back to peer", peerCtx, "subject", "peer:127.0.0.1"},
+		{"empty subject falls back to peer", context.WithValue(peerCtx, claimsKey{}, jwt.RegisteredClaims{}), "subject", "peer:127.0.0.1"},
+		{"api key", metadata.NewIncomingContext(peerCtx, metadata.Pairs("x-api-key", "secret")), "apiKey", "apiKey:" + hex.EncodeToString(sum[:])},
+		{"api key falls back to peer", peerCtx, "apiKey", "peer:127.0.0.1"},
+		{"peer", withClaims, "peer", "peer:127.0.0.1"},
+		{"x-forwarded-for is ignored", metadata.NewIncomingContext(peerCtx, metadata.Pairs("x-forwarded-for", "10.0.0.1")), "peer", "peer:127.0.0.1"},
+		{"gateway client", trustClientAddress(metadata.NewIncomingContext(peerCtx, metadata.Pairs(clientAddressHeader, "10.0.0.1:5000", gatewayTokenHeader, "token")), "token"), "peer", "peer:10.0.0.1"},
+		{"gateway client with wrong token", trustClientAddress(metadata.NewIncomingContext(peerCtx, metadata.Pairs(clientAddressHeader, "10.0.0.1:5000", gatewayTokenHeader, "forged")), "token"), "peer", "peer:127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateLimitKey(tt.ctx, tt.kind, "x-api-key"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
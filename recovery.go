@@ -0,0 +1,95 @@
+package boilerplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errPanic is returned to clients instead of the panic value, which may
+// expose internals
+var errPanic = status.Error(codes.Internal, "internal error")
+
+// recovery turns panics in handlers into errors, so a single request can not
+// take down the server
+type recovery struct {
+	logger *slog.Logger
+	panics metric.Int64Counter
+}
+
+func newRecovery(logger *slog.Logger, mp metric.MeterProvider) (*recovery, error) {
+	panics, err := mp.Meter(instrumentationName).Int64Counter("boilerplate.panics",
+		metric.WithDescription("Number of panics recovered from in request handlers."),
+		metric.WithUnit("{panic}"))
+	if err != nil {
+		return nil, err
+	}
+	return &recovery{logger: logger, panics: panics}, nil
+}
+
+// handle is called from the deferred recover, so the stack still contains
+// the panicking frames
+func (rc *recovery) handle(ctx context.Context, p any, attrs ...attribute.KeyValue) {
+	stack := string(debug.Stack())
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(fmt.Errorf("panic: %v", p), trace.WithAttributes(semconv.ExceptionStacktrace(stack)))
+	span.SetStatus(otelcodes.Error, "panic")
+
+	rc.panics.Add(ctx, 1, metric.WithAttributes(attrs...))
+	rc.logger.ErrorContext(ctx, "recovered from panic", "panic", fmt.Sprint(p), "stack", stack)
+}
+
+func (rc *recovery) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				rc.handle(ctx, p, rpcAttributes(info.FullMethod)...)
+				resp, err = nil, errPanic
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func (rc *recovery) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				rc.handle(ss.Context(), p, rpcAttributes(info.FullMethod)...)
+				err = errPanic
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func (rc *recovery) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// net/http aborts the response silently on ErrAbortHandler
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			rc.handle(r.Context(), p, semconv.HTTPRequestMethodKey.String(r.Method))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
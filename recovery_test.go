@@ -0,0 +1,133 @@
+package boilerplate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func newTestRecovery(t *testing.T) (*recovery, *bytes.Buffer, *sdkmetric.ManualReader) {
+	t.Helper()
+	var logs bytes.Buffer
+	reader := sdkmetric.NewManualReader()
+	rc, err := newRecovery(slog.New(slog.NewTextHandler(&logs, nil)), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc, &logs, reader
+}
+
+func TestRecovery(t *testing.T) {
+	const method = "/greeter.v1.GreeterService/SayHello"
+
+	unary := func(rc *recovery, handler func()) (string, string) {
+		_, err := rc.unaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req any) (any, error) {
+				handler()
+				return "ok", nil
+			})
+		return status.Code(err).String(), status.Convert(err).Message()
+	}
+	stream := func(rc *recovery, handler func()) (string, string) {
+		ss := testServerStream{ctx: context.Background()}
+		err := rc.streamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: method},
+			func(srv any, ss grpc.ServerStream) error {
+				handler()
+				return nil
+			})
+		return status.Code(err).String(), status.Convert(err).Message()
+	}
+	gateway := func(rc *recovery, handler func()) (string, string) {
+		w := httptest.NewRecorder()
+		rc.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler()
+		})).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/hello", nil))
+		return strconv.Itoa(w.Code), strings.TrimSpace(w.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		serve       func(rc *recovery, handler func()) (string, string)
+		panicValue  any
+		wantCode    string
+		wantMessage string
+		wantAttrs   []attribute.KeyValue
+	}{
+		{"unary", unary, "secret 1234", "Internal", "internal error", rpcAttributes(method)},
+		{"unary with error value", unary, errors.New("secret 1234"), "Internal", "internal error", rpcAttributes(method)},
+		{"unary without panic", unary, nil, "OK", "", nil},
+		{"stream", stream, "secret 1234", "Internal", "internal error", rpcAttributes(method)},
+		{"stream without panic", stream, nil, "OK", "", nil},
+		{"gateway", gateway, "secret 1234", "500", "Internal Server Error", []attribute.KeyValue{attribute.String("http.request.method", http.MethodPost)}},
+		{"gateway without panic", gateway, nil, "200", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, logs, reader := newTestRecovery(t)
+
+			code, message := tt.serve(rc, func() {
+				if tt.panicValue != nil {
+					panic(tt.panicValue)
+				}
+			})
+			if code != tt.wantCode || message != tt.wantMessage {
+				t.Errorf("got %s %q, want %s %q", code, message, tt.wantCode, tt.wantMessage)
+			}
+
+			recovered := tt.panicValue != nil
+			if got := strings.Contains(logs.String(), `msg="recovered from panic" panic="secret 1234"`); got != recovered {
+				t.Errorf("logged panic = %v, want %v:\n%s", got, recovered, logs)
+			}
+
+			metrics := collectReader(t, reader)
+			data, ok := metrics["boilerplate.panics"]
+			if !recovered {
+				if ok {
+					t.Errorf("recorded a panic")
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("no panic recorded")
+			}
+			attrs := attribute.NewSet(tt.wantAttrs...)
+			want := map[string]int64{attrs.Encoded(attribute.DefaultEncoder()): 1}
+			if got := dataPoints(t, data); !reflect.DeepEqual(got, want) {
+				t.Errorf("got panics %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRecoveryRepanicsErrAbortHandler(t *testing.T) {
+	rc, logs, reader := newTestRecovery(t)
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("got panic %v, want http.ErrAbortHandler", p)
+		}
+		if logs.Len() > 0 {
+			t.Errorf("logged the aborted handler: %s", logs)
+		}
+		if _, ok := collectReader(t, reader)["boilerplate.panics"]; ok {
+			t.Error("recorded the aborted handler as panic")
+		}
+	}()
+
+	rc.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,109 @@
+package boilerplate
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+)
+
+const reflectionMethodPrefix = "/grpc.reflection."
+
+// reflectionAuth only lets reflection calls through that carry a valid bearer
+// token issued by one of the configured jwks endpoints for the configured
+// audience, with the required claims. Without endpoints reflection is open.
+// The settings are hot reloadable.
+type reflectionAuth struct {
+	mu       sync.RWMutex
+	keyfunc  keyfunc.Keyfunc
+	audience string
+	claims   map[string]string
+	cancel   context.CancelFunc
+}
+
+func newReflectionAuth(ctx context.Context, conf ReflectionConfig) (*reflectionAuth, error) {
+	a := &reflectionAuth{}
+	return a, a.update(ctx, conf)
+}
+
+// update replaces the key sets and stops refreshing the previous ones
+func (a *reflectionAuth) update(ctx context.Context, conf ReflectionConfig) error {
+	var kf keyfunc.Keyfunc
+	cancel := func() {}
+
+	if len(conf.JwksUrls) > 0 {
+		var kfCtx context.Context
+		kfCtx, cancel = context.WithCancel(ctx)
+		var err error
+		if kf, err = keyfunc.NewDefaultCtx(kfCtx, conf.JwksUrls); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	previous := a.cancel
+	a.keyfunc, a.cancel = kf, cancel
+	a.audience, a.claims = conf.Audience, conf.Claims
+	a.mu.Unlock()
+
+	if previous != nil {
+		previous()
+	}
+	return nil
+}
+
+func (a *reflectionAuth) streamInterceptor() grpc.StreamServerInterceptor {
+	claimsFunc := func() jwt.MapClaims { return jwt.MapClaims{} }
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, reflectionMethodPrefix) {
+			return handler(srv, ss)
+		}
+
+		a.mu.RLock()
+		kf, audience, required := a.keyfunc, a.audience, a.claims
+		a.mu.RUnlock()
+
+		if kf == nil {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), kf, claimsFunc, true)
+		if err != nil {
+			return err
+		}
+		if claims, _ := GetClaimsFromContext[jwt.MapClaims](ctx); !authorizedClaims(claims, audience, required) {
+			// tokens of other services of the same issuer are valid as well
+			recordAuthFailure(ctx, errInvalidToken)
+			return errInvalidToken
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorizedClaims checks the audience and the required claims. A required
+// claim matches a string claim of the same value or a list containing it.
+func authorizedClaims(claims jwt.MapClaims, audience string, required map[string]string) bool {
+	if aud, err := claims.GetAudience(); err != nil || !slices.Contains(aud, audience) {
+		return false
+	}
+	for name, want := range required {
+		switch got := claims[name].(type) {
+		case string:
+			if got != want {
+				return false
+			}
+		case []any:
+			if !slices.Contains(got, any(want)) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package boilerplate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MicahParks/jwkset"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testSigningKey = []byte("test-signing-key")
+
+// testKeyfunc verifies hmac signed tokens instead of fetching a jwks
+type testKeyfunc struct{}
+
+func (testKeyfunc) Keyfunc(token *jwt.Token) (any, error) {
+	return testSigningKey, nil
+}
+
+func (f testKeyfunc) KeyfuncCtx(ctx context.Context) jwt.Keyfunc {
+	return f.Keyfunc
+}
+
+func (testKeyfunc) Storage() jwkset.Storage {
+	return nil
+}
+
+func signTestToken(t *testing.T, claims jwt.MapClaims, key []byte) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestReflectionAuth(t *testing.T) {
+	const reflectionMethod = "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+
+	auth := &reflectionAuth{
+		keyfunc:  testKeyfunc{},
+		audience: "grpc-reflection",
+		claims:   map[string]string{"role": "operator"},
+	}
+
+	tests := []struct {
+		name     string
+		auth     *reflectionAuth
+		method   string
+		token    string
+		wantCode codes.Code
+	}{
+		{
+			name:     "audience and claim",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": "grpc-reflection", "role": "operator"}, testSigningKey),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "audience list and claim list",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": []string{"greeter", "grpc-reflection"}, "role": []string{"reader", "operator"}}, testSigningKey),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "token for another audience",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": "greeter", "role": "operator"}, testSigningKey),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "token without audience",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"role": "operator"}, testSigningKey),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "missing claim",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": "grpc-reflection"}, testSigningKey),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "wrong claim value",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": "grpc-reflection", "role": "reader"}, testSigningKey),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "invalid signature",
+			auth:     auth,
+			method:   reflectionMethod,
+			token:    signTestToken(t, jwt.MapClaims{"aud": "grpc-reflection", "role": "operator"}, []byte("other-key")),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "missing token",
+			auth:     auth,
+			method:   reflectionMethod,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "other methods are not checked",
+			auth:     auth,
+			method:   "/greeter.v1.GreeterService/SayHello",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "open without jwks urls",
+			auth:     &reflectionAuth{},
+			method:   reflectionMethod,
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.token != "" {
+				md.Set("authorization", "Bearer "+tt.token)
+			}
+			stream := testServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+			called := false
+			handler := func(srv any, ss grpc.ServerStream) error {
+				called = true
+				return nil
+			}
+
+			err := tt.auth.streamInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s (%v)", code, tt.wantCode, err)
+			}
+			if called != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
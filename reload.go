@@ -0,0 +1,155 @@
+package boilerplate
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_RELOAD_INTERVAL = 5 * time.Second
+
+// ConfigSubscriber is notified after a reload changed the running config.
+// new only contains changes of hot reloadable fields, all others require a
+// restart.
+type ConfigSubscriber func(old, new BoilerplateConfig)
+
+// hotReloadable lists the config fields (and field prefixes) that are applied
+// at runtime without a restart. Other auth settings, like admin.token, are
+// read once at startup.
+var hotReloadable = []string{
+	"gateway.allowedOrigins",
+	"gateway.allowedMethods",
+	"gateway.allowedHeaders",
+	"grpc.reflection.jwksUrls",
+	"grpc.reflection.audience",
+	"grpc.reflection.claims",
+	"otel.sampler",
+	"log.level",
+	"rateLimit.key",
+	"rateLimit.default",
+	"rateLimit.rules",
+}
+
+func isHotReloadable(key string) bool {
+	for _, prefix := range hotReloadable {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConfig returns the keys of all fields that differ
+func diffConfig(old, new BoilerplateConfig) []string {
+	newFields := configFieldsByKey(&new)
+
+	var changed []string
+	for _, field := range configFields(&old) {
+		if !reflect.DeepEqual(field.value.Interface(), newFields[field.key()].value.Interface()) {
+			changed = append(changed, field.key())
+		}
+	}
+	return changed
+}
+
+func (s *boilerplate) Config() BoilerplateConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+func (s *boilerplate) subscribe(f ConfigSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, f)
+}
+
+// watchConfig reloads the config file when it changes or on SIGHUP
+func (s *boilerplate) watchConfig(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DEFAULT_RELOAD_INTERVAL
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastMod := modTime(s.source.path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.logger.Info("received SIGHUP, reloading config", "path", s.source.path)
+			s.reloadConfig()
+		case <-ticker.C:
+			if mod := modTime(s.source.path); !mod.Equal(lastMod) {
+				lastMod = mod
+				s.logger.Info("config changed, reloading", "path", s.source.path)
+				s.reloadConfig()
+			}
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (s *boilerplate) reloadConfig() {
+	// the builder settings are applied over the file, environment and flags
+	// again, as they were at startup
+	conf, err := s.source.load()
+	if err == nil {
+		for _, override := range s.overrides {
+			override(&conf)
+		}
+		err = conf.Validate()
+	}
+	if err != nil {
+		s.logger.Error("could not reload config, keeping the running config", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	old := s.config
+	next := s.config
+
+	nextFields := configFieldsByKey(&next)
+	loadedFields := configFieldsByKey(&conf)
+
+	applied := false
+	for _, key := range diffConfig(old, conf) {
+		if !isHotReloadable(key) {
+			s.logger.Warn("config field changed, restart required to apply", "field", key)
+			continue
+		}
+		nextFields[key].value.Set(loadedFields[key].value)
+		applied = true
+		s.logger.Info("config field changed, applied", "field", key)
+	}
+
+	s.config = next
+	subscribers := append([]ConfigSubscriber{}, s.subscribers...)
+	s.mu.Unlock()
+
+	if !applied {
+		return
+	}
+
+	for _, subscriber := range subscribers {
+		subscriber(old, next)
+	}
+}
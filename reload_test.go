@@ -0,0 +1,242 @@
+package boilerplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestIsHotReloadable(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"log.level", true},
+		{"log.format", false},
+		{"gateway.allowedOrigins", true},
+		{"otel.sampler.ratio", true},
+		{"otel.samplerx", false},
+		{"rateLimit.rules", true},
+		{"rateLimit.enabled", false},
+		{"grpc.reflection.jwksUrls", true},
+		{"grpc.reflection.audience", true},
+		{"grpc.reflection.claims", true},
+		{"grpc.reflection.enabled", false},
+		{"admin.token", false},
+		{"grpc.addr", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isHotReloadable(tt.key); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *BoilerplateConfig)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(c *BoilerplateConfig) {},
+		},
+		{
+			name: "nested fields",
+			change: func(c *BoilerplateConfig) {
+				c.Log.Level = "debug"
+				c.Grpc.Reflection.Audience = "grpc-reflection"
+			},
+			want: []string{"grpc.reflection.audience", "log.level"},
+		},
+		{
+			name: "slices and maps",
+			change: func(c *BoilerplateConfig) {
+				c.Gateway.AllowedOrigins = []string{"https://example.com"}
+				c.Grpc.Reflection.Claims = map[string]string{"role": "operator"}
+			},
+			want: []string{"gateway.allowedOrigins", "grpc.reflection.claims"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := defaultConfig
+			tt.change(&next)
+			got := diffConfig(defaultConfig, next)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// reloadLogs collects the fields of the reload messages by message
+func reloadLogs(t *testing.T, buf *bytes.Buffer) map[string][]string {
+	t.Helper()
+	logs := map[string][]string{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record struct {
+			Msg   string `json:"msg"`
+			Field string `json:"field"`
+		}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		logs[record.Msg] = append(logs[record.Msg], record.Field)
+	}
+	for _, fields := range logs {
+		slices.Sort(fields)
+	}
+	return logs
+}
+
+func TestReloadConfig(t *testing.T) {
+	const (
+		applied = "config field changed, applied"
+		restart = "config field changed, restart required to apply"
+		failed  = "could not reload config, keeping the running config"
+	)
+
+	tests := []struct {
+		name        string
+		initial     string
+		next        string
+		overrides   []func(c *BoilerplateConfig)
+		wantApplied []string
+		wantRestart []string
+		wantFailed  bool
+		wantCalls   int
+		check       func(t *testing.T, conf BoilerplateConfig)
+	}{
+		{
+			name:        "hot reloadable fields are applied",
+			initial:     "log:\n  level: info\n",
+			next:        "log:\n  level: debug\ngateway:\n  allowedOrigins: [\"https://example.com\"]\n",
+			wantApplied: []string{"gateway.allowedOrigins", "log.level"},
+			wantCalls:   1,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.Log.Level != "debug" || !slices.Equal(conf.Gateway.AllowedOrigins, []string{"https://example.com"}) {
+					t.Errorf("changes not applied: %q %v", conf.Log.Level, conf.Gateway.AllowedOrigins)
+				}
+			},
+		},
+		{
+			name:        "other fields require a restart",
+			initial:     "serviceName: greeter\n",
+			next:        "serviceName: greeter\ngrpc:\n  addr: \":6000\"\nadmin:\n  token: s3cr3t\n",
+			wantRestart: []string{"admin.token", "grpc.addr"},
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.Grpc.Addr != DEFAULT_GRPC_ADDR || conf.Admin.Token != "" {
+					t.Errorf("restart required fields changed: %q %q", conf.Grpc.Addr, conf.Admin.Token)
+				}
+			},
+		},
+		{
+			name:        "mixed changes",
+			initial:     "log:\n  level: info\n",
+			next:        "log:\n  level: warn\n  format: json\n",
+			wantApplied: []string{"log.level"},
+			wantRestart: []string{"log.format"},
+			wantCalls:   1,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.Log.Level != "warn" || conf.Log.Format != defaultConfig.Log.Format {
+					t.Errorf("got level %q format %q", conf.Log.Level, conf.Log.Format)
+				}
+			},
+		},
+		{
+			name:    "builder settings are applied again",
+			initial: "serviceName: file\nlog:\n  level: info\n",
+			next:    "serviceName: changed\nlog:\n  level: debug\nrateLimit:\n  key: subject\n",
+			overrides: []func(c *BoilerplateConfig){
+				func(c *BoilerplateConfig) { c.ServiceName = "builder" },
+				func(c *BoilerplateConfig) { c.Log.Level = "error" },
+			},
+			wantApplied: []string{"rateLimit.key"},
+			wantCalls:   1,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.ServiceName != "builder" || conf.Log.Level != "error" {
+					t.Errorf("builder settings lost: %q %q", conf.ServiceName, conf.Log.Level)
+				}
+			},
+		},
+		{
+			name:       "invalid config keeps the running config",
+			initial:    "log:\n  level: info\n",
+			next:       "log:\n  level: loud\n",
+			wantFailed: true,
+			check: func(t *testing.T, conf BoilerplateConfig) {
+				if conf.Log.Level != "info" {
+					t.Errorf("got level %q, want info", conf.Log.Level)
+				}
+			},
+		},
+		{
+			name:    "unchanged file",
+			initial: "log:\n  level: info\n",
+			next:    "log:\n  level: info\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", tt.initial)
+
+			var buf bytes.Buffer
+			s := &boilerplate{logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+			s.WithConfigFile(path)
+			if s.configErr != nil {
+				t.Fatal(s.configErr)
+			}
+			for _, override := range tt.overrides {
+				s.set(override)
+			}
+
+			calls := 0
+			s.OnConfigChange(func(old, new BoilerplateConfig) {
+				calls++
+				if !slices.Equal(diffConfig(old, new), tt.wantApplied) {
+					t.Errorf("subscriber got changes %v, want %v", diffConfig(old, new), tt.wantApplied)
+				}
+			})
+
+			if err := os.WriteFile(path, []byte(tt.next), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			// the second reload finds nothing new
+			s.reloadConfig()
+			s.reloadConfig()
+
+			if calls != tt.wantCalls {
+				t.Errorf("subscriber called %d times, want %d", calls, tt.wantCalls)
+			}
+
+			logs := reloadLogs(t, &buf)
+			if !slices.Equal(logs[applied], tt.wantApplied) {
+				t.Errorf("applied %v, want %v", logs[applied], tt.wantApplied)
+			}
+			// a field requiring a restart is reported on every reload
+			wantRestart := append(slices.Clone(tt.wantRestart), tt.wantRestart...)
+			slices.Sort(wantRestart)
+			if !slices.Equal(logs[restart], wantRestart) {
+				t.Errorf("restart required %v, want %v", logs[restart], wantRestart)
+			}
+			if got := len(logs[failed]) > 0; got != tt.wantFailed {
+				t.Errorf("reload failed = %v, want %v", got, tt.wantFailed)
+			}
+			if tt.check != nil {
+				tt.check(t, s.Config())
+			}
+		})
+	}
+}
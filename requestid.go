@@ -0,0 +1,124 @@
+package boilerplate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// GetRequestIDFromContext returns the id of the request being handled, or
+// the one set with ContextWithRequestID
+func GetRequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// ContextWithRequestID sets the id the client interceptors forward
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// UnaryClientRequestIDInterceptor forwards the request id of the context to
+// the called service
+func UnaryClientRequestIDInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestIDInterceptor forwards the request id of the context to
+// the called service
+func StreamClientRequestIDInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	id, ok := GetRequestIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(requestIDHeader)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIDHeader, id)
+}
+
+// incomingRequestID takes the id passed by the client, or generates one
+func incomingRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+			return ids[0]
+		}
+	}
+	return newRequestID()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID keeps ids that would garble logs or headers from being
+// passed on
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func requestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := incomingRequestID(ctx)
+		md := metadata.Pairs(requestIDHeader, id)
+		_ = grpc.SetHeader(ctx, md)
+		_ = grpc.SetTrailer(ctx, md)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		return handler(ContextWithRequestID(ctx, id), req)
+	}
+}
+
+func requestIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		id := incomingRequestID(ctx)
+		md := metadata.Pairs(requestIDHeader, id)
+		_ = ss.SetHeader(md)
+		ss.SetTrailer(md)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ContextWithRequestID(ctx, id)})
+	}
+}
+
+// requestIDHandler makes sure every gateway request carries an id. The header
+// is forwarded to the grpc server as metadata, so both log the same id.
+func requestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(requestIDHeader, id)
+		}
+		w.Header().Set(requestIDHeader, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+		h.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,217 @@
+package boilerplate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// transportStream records the header and trailer set by a unary interceptor
+type transportStream struct {
+	header, trailer metadata.MD
+}
+
+func (s *transportStream) Method() string { return "/greeter.v1.GreeterService/SayHello" }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// headerStream records the header and trailer set by a stream interceptor
+type headerStream struct {
+	testServerStream
+	transport transportStream
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	return s.transport.SetHeader(md)
+}
+
+func (s *headerStream) SetTrailer(md metadata.MD) {
+	_ = s.transport.SetTrailer(md)
+}
+
+func isGeneratedRequestID(id string) bool {
+	return len(id) == 32 && strings.Trim(id, "0123456789abcdef") == ""
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"uuid", "6f1c5a2e-8d4b-4a61-9c3e-2b7d9f0a1e54", true},
+		{"printable ascii", "abc!~123", true},
+		{"empty", "", false},
+		{"space", "abc 123", false},
+		{"newline", "abc\n123", false},
+		{"non ascii", "abcä", false},
+		{"128 characters", strings.Repeat("a", 128), true},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validRequestID(tt.id); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDInterceptors(t *testing.T) {
+	unary := func(ctx context.Context) (string, metadata.MD, metadata.MD) {
+		stream := &transportStream{}
+		var id string
+		_, _ = requestIDUnaryInterceptor()(grpc.NewContextWithServerTransportStream(ctx, stream), nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, req any) (any, error) {
+				id, _ = GetRequestIDFromContext(ctx)
+				return nil, nil
+			})
+		return id, stream.header, stream.trailer
+	}
+	streaming := func(ctx context.Context) (string, metadata.MD, metadata.MD) {
+		stream := &headerStream{testServerStream: testServerStream{ctx: ctx}}
+		var id string
+		_ = requestIDStreamInterceptor()(nil, stream, &grpc.StreamServerInfo{},
+			func(srv any, ss grpc.ServerStream) error {
+				id, _ = GetRequestIDFromContext(ss.Context())
+				return nil
+			})
+		return id, stream.transport.header, stream.transport.trailer
+	}
+
+	tests := []struct {
+		name      string
+		intercept func(ctx context.Context) (string, metadata.MD, metadata.MD)
+		md        metadata.MD
+		want      string
+	}{
+		{"unary passes the client's id on", unary, metadata.Pairs(requestIDHeader, "client-id"), "client-id"},
+		{"unary generates a missing id", unary, nil, ""},
+		{"unary replaces an invalid id", unary, metadata.Pairs(requestIDHeader, "client id"), ""},
+		{"stream passes the client's id on", streaming, metadata.Pairs(requestIDHeader, "client-id"), "client-id"},
+		{"stream generates a missing id", streaming, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			id, header, trailer := tt.intercept(ctx)
+			if tt.want != "" && id != tt.want {
+				t.Errorf("got id %q, want %q", id, tt.want)
+			}
+			if tt.want == "" && !isGeneratedRequestID(id) {
+				t.Errorf("got id %q, want a generated one", id)
+			}
+			for name, md := range map[string]metadata.MD{"header": header, "trailer": trailer} {
+				if got := md.Get(requestIDHeader); len(got) != 1 || got[0] != id {
+					t.Errorf("got %s %v, want [%s]", name, got, id)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestIDClientInterceptors(t *testing.T) {
+	unary := func(ctx context.Context) metadata.MD {
+		var md metadata.MD
+		_ = UnaryClientRequestIDInterceptor()(ctx, "/greeter.v1.GreeterService/SayHello", nil, nil, nil,
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ = metadata.FromOutgoingContext(ctx)
+				return nil
+			})
+		return md
+	}
+	streaming := func(ctx context.Context) metadata.MD {
+		var md metadata.MD
+		_, _ = StreamClientRequestIDInterceptor()(ctx, &grpc.StreamDesc{}, nil, "/greeter.v1.GreeterService/SayHello",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				md, _ = metadata.FromOutgoingContext(ctx)
+				return nil, nil
+			})
+		return md
+	}
+
+	withID := ContextWithRequestID(context.Background(), "request-id")
+
+	tests := []struct {
+		name      string
+		intercept func(ctx context.Context) metadata.MD
+		ctx       context.Context
+		want      []string
+	}{
+		{"unary forwards the id", unary, withID, []string{"request-id"}},
+		{"stream forwards the id", streaming, withID, []string{"request-id"}},
+		{"unary without id", unary, context.Background(), nil},
+		{"id set by the caller is kept", unary, metadata.AppendToOutgoingContext(withID, requestIDHeader, "own-id"), []string{"own-id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.intercept(tt.ctx).Get(requestIDHeader)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"passes the client's id on", "client-id", "client-id"},
+		{"generates a missing id", "", ""},
+		{"replaces an invalid id", strings.Repeat("a", 129), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+			if tt.header != "" {
+				r.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			var ctxID, forwarded string
+			requestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID, _ = GetRequestIDFromContext(r.Context())
+				forwarded = r.Header.Get(requestIDHeader)
+			})).ServeHTTP(w, r)
+
+			id := w.Header().Get(requestIDHeader)
+			if tt.want != "" && id != tt.want {
+				t.Errorf("got id %q, want %q", id, tt.want)
+			}
+			if tt.want == "" && !isGeneratedRequestID(id) {
+				t.Errorf("got id %q, want a generated one", id)
+			}
+			if ctxID != id || forwarded != id {
+				t.Errorf("got context id %q and forwarded header %q, want %q", ctxID, forwarded, id)
+			}
+		})
+	}
+}
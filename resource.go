@@ -0,0 +1,121 @@
+package boilerplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+var validResourceDetectors = []string{"host", "os", "process", "container", "kubernetes"}
+
+// newResource builds the resource shared by all providers. Explicit config
+// wins over OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES, which win over
+// detected attributes. The default service name applies if none of them
+// sets one.
+func newResource(ctx context.Context, conf OtelConfig, serviceName string, logger *slog.Logger) (*resource.Resource, error) {
+	options := []resource.Option{
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(DEFAULT_SERVICE_NAME)),
+	}
+
+	for _, detector := range conf.Resource.Detectors {
+		switch detector {
+		case "host":
+			options = append(options, resource.WithHost(), resource.WithHostID())
+		case "os":
+			options = append(options, resource.WithOS())
+		case "process":
+			options = append(options, resource.WithProcess())
+		case "container":
+			options = append(options, resource.WithContainer())
+		case "kubernetes":
+			options = append(options, resource.WithDetectors(kubernetesDetector{}))
+		default:
+			return nil, fmt.Errorf("unknown resource detector '%s'", detector)
+		}
+	}
+
+	options = append(options,
+		resource.WithFromEnv(),
+		resource.WithAttributes(conf.Resource.attributes(serviceName)...),
+	)
+
+	res, err := resource.New(ctx, options...)
+	if errors.Is(err, resource.ErrPartialResource) {
+		// a failing detector should not keep the service from starting
+		logger.WarnContext(ctx, "some resource attributes could not be detected", "error", err)
+		return res, nil
+	}
+	return res, err
+}
+
+func (c ResourceConfig) attributes(serviceName string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+
+	if serviceName != "" {
+		attrs = append(attrs, semconv.ServiceName(serviceName))
+	}
+	if c.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(c.ServiceVersion))
+	}
+	if c.ServiceNamespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespace(c.ServiceNamespace))
+	}
+	if c.ServiceInstanceID != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(c.ServiceInstanceID))
+	}
+	if c.DeploymentEnvironment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(c.DeploymentEnvironment))
+	}
+
+	for k, v := range c.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
+
+// kubernetesDetector reads pod metadata exposed through the downward api,
+// e.g.
+//
+//	env:
+//	  - name: K8S_POD_NAME
+//	    valueFrom:
+//	      fieldRef:
+//	        fieldPath: metadata.name
+type kubernetesDetector struct{}
+
+var kubernetesEnv = []struct {
+	key  attribute.Key
+	envs []string
+}{
+	{semconv.K8SPodNameKey, []string{"K8S_POD_NAME", "POD_NAME"}},
+	{semconv.K8SPodUIDKey, []string{"K8S_POD_UID", "POD_UID"}},
+	{semconv.K8SNamespaceNameKey, []string{"K8S_NAMESPACE_NAME", "POD_NAMESPACE"}},
+	{semconv.K8SNodeNameKey, []string{"K8S_NODE_NAME", "NODE_NAME"}},
+	{semconv.K8SContainerNameKey, []string{"K8S_CONTAINER_NAME", "CONTAINER_NAME"}},
+	{semconv.K8SDeploymentNameKey, []string{"K8S_DEPLOYMENT_NAME"}},
+}
+
+func (kubernetesDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for _, entry := range kubernetesEnv {
+		for _, env := range entry.envs {
+			if v := os.Getenv(env); v != "" {
+				attrs = append(attrs, entry.key.String(v))
+				break
+			}
+		}
+	}
+
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
@@ -0,0 +1,47 @@
+package boilerplate
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var patternVariable = regexp.MustCompile(`\{([^=}]+)=\*\}`)
+
+// routeFromPattern turns a grpc-gateway pattern like /v1/hello/{name=*} into
+// the http.route form /v1/hello/{name}
+func routeFromPattern(pattern runtime.Pattern) string {
+	return patternVariable.ReplaceAllString(pattern.String(), "{$1}")
+}
+
+// routeMiddleware names the otelhttp server span after the matched route and
+// hands it to the access log. Only the gateway mux knows the route, so this
+// runs inside of it.
+func routeMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+			route := routeFromPattern(pattern)
+
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+
+			if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+				labeler.Add(semconv.HTTPRoute(route))
+			}
+
+			accessLogEntryFromContext(r.Context()).setRoute(route)
+		}
+		next(w, r, pathParams)
+	}
+}
+
+// spanNameFromMethod is used until the route is known, e.g. for requests that
+// do not match any route
+func spanNameFromMethod(operation string, r *http.Request) string {
+	return r.Method
+}
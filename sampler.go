@@ -0,0 +1,192 @@
+package boilerplate
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DEFAULT_SAMPLER       = "parentbased_always_on"
+	DEFAULT_SAMPLER_RATIO = 1.0
+)
+
+var validSamplers = []string{
+	"always_on",
+	"always_off",
+	"traceidratio",
+	"parentbased_always_on",
+	"parentbased_always_off",
+	"parentbased_traceidratio",
+}
+
+// newSampler composes the configured sampler: per span rules take precedence
+// over the base sampler, the rate limit caps the traces started here.
+func newSampler(conf SamplerConfig) sdktrace.Sampler {
+	kind, ratio := conf.Type, conf.Ratio
+	if kind == "" {
+		kind, ratio = samplerFromEnv()
+	}
+
+	parentBased := strings.HasPrefix(kind, "parentbased_")
+	kind = strings.TrimPrefix(kind, "parentbased_")
+
+	var root sdktrace.Sampler
+	switch kind {
+	case "always_off":
+		root = sdktrace.NeverSample()
+	case "traceidratio":
+		root = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		root = sdktrace.AlwaysSample()
+	}
+
+	if len(conf.Rules) > 0 {
+		root = ruleSampler{rules: conf.Rules, fallback: root}
+	}
+
+	if conf.RateLimit > 0 {
+		burst := int(math.Max(1, math.Ceil(conf.RateLimit)))
+		root = rateLimitSampler{next: root, limiter: rate.NewLimiter(rate.Limit(conf.RateLimit), burst)}
+	}
+
+	sampler := root
+	if parentBased {
+		sampler = sdktrace.ParentBased(root)
+	}
+
+	if conf.KeepErrors {
+		sampler = recordOnlySampler{next: sampler}
+	}
+	return sampler
+}
+
+// samplerFromEnv reads OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG
+func samplerFromEnv() (string, float64) {
+	kind := strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER"))
+	if !slices.Contains(validSamplers, kind) {
+		kind = DEFAULT_SAMPLER
+	}
+
+	ratio, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		ratio = DEFAULT_SAMPLER_RATIO
+	}
+	return kind, ratio
+}
+
+// dynamicSampler delegates to a sampler that can be swapped on config reload
+type dynamicSampler struct {
+	current atomic.Pointer[sdktrace.Sampler]
+}
+
+func newDynamicSampler(conf SamplerConfig) *dynamicSampler {
+	d := &dynamicSampler{}
+	d.update(conf)
+	return d
+}
+
+func (d *dynamicSampler) update(conf SamplerConfig) {
+	sampler := newSampler(conf)
+	d.current.Store(&sampler)
+}
+
+func (d *dynamicSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*d.current.Load()).ShouldSample(p)
+}
+
+func (d *dynamicSampler) Description() string {
+	return (*d.current.Load()).Description()
+}
+
+// ruleSampler applies the ratio of the first rule matching the span name
+type ruleSampler struct {
+	rules    []SamplerRule
+	fallback sdktrace.Sampler
+}
+
+func (s ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, rule := range s.rules {
+		if ok, _ := path.Match(rule.Span, p.Name); ok {
+			return sdktrace.TraceIDRatioBased(rule.Ratio).ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s ruleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{rules:%d,fallback:%s}", len(s.rules), s.fallback.Description())
+}
+
+// rateLimitSampler drops sampled traces once the limit is exhausted. Only
+// root spans count against the limit, so traces are kept or dropped whole.
+type rateLimitSampler struct {
+	next    sdktrace.Sampler
+	limiter *rate.Limiter
+}
+
+func (s rateLimitSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.next.ShouldSample(p)
+	if result.Decision != sdktrace.RecordAndSample || trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return result
+	}
+	if !s.limiter.Allow() {
+		result.Decision = sdktrace.Drop
+	}
+	return result
+}
+
+func (s rateLimitSampler) Description() string {
+	return fmt.Sprintf("RateLimitSampler{%g/s,%s}", float64(s.limiter.Limit()), s.next.Description())
+}
+
+// recordOnlySampler records dropped spans, so errorSpanProcessor can still
+// export them if they end with an error
+type recordOnlySampler struct {
+	next sdktrace.Sampler
+}
+
+func (s recordOnlySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.next.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (s recordOnlySampler) Description() string {
+	return fmt.Sprintf("RecordOnlySampler{%s}", s.next.Description())
+}
+
+// errorSpanProcessor hands recorded, but not sampled spans to the next
+// processor if they ended with an error. Parents of such spans may have been
+// dropped, so kept error traces can be incomplete.
+type errorSpanProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func (p errorSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() && s.Status().Code == codes.Error {
+		s = sampledSpan{s}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
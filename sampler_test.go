@@ -0,0 +1,181 @@
+package boilerplate
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testParent int
+
+const (
+	noParent testParent = iota
+	sampledParent
+	unsampledParent
+)
+
+func samplingParameters(parent testParent, name string) sdktrace.SamplingParameters {
+	traceID := trace.TraceID{1}
+	ctx := context.Background()
+	if parent != noParent {
+		var flags trace.TraceFlags
+		if parent == sampledParent {
+			flags = flags.WithSampled(true)
+		}
+		ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+		}))
+	}
+	return sdktrace.SamplingParameters{ParentContext: ctx, TraceID: traceID, Name: name}
+}
+
+func TestNewSampler(t *testing.T) {
+	type step struct {
+		parent testParent
+		name   string
+		want   sdktrace.SamplingDecision
+	}
+
+	tests := []struct {
+		name  string
+		conf  SamplerConfig
+		env   map[string]string
+		steps []step
+	}{
+		{
+			name: "always_on",
+			conf: SamplerConfig{Type: "always_on"},
+			steps: []step{
+				{noParent, "span", sdktrace.RecordAndSample},
+				{unsampledParent, "span", sdktrace.RecordAndSample},
+			},
+		},
+		{
+			name: "always_off",
+			conf: SamplerConfig{Type: "always_off"},
+			steps: []step{
+				{noParent, "span", sdktrace.Drop},
+				{sampledParent, "span", sdktrace.Drop},
+			},
+		},
+		{
+			name: "parentbased_always_off follows the parent",
+			conf: SamplerConfig{Type: "parentbased_always_off"},
+			steps: []step{
+				{noParent, "span", sdktrace.Drop},
+				{sampledParent, "span", sdktrace.RecordAndSample},
+				{unsampledParent, "span", sdktrace.Drop},
+			},
+		},
+		{
+			name: "traceidratio keeps everything by default",
+			conf: SamplerConfig{Type: "traceidratio", Ratio: defaultConfig.Otel.Sampler.Ratio},
+			steps: []step{
+				{noParent, "span", sdktrace.RecordAndSample},
+			},
+		},
+		{
+			name: "traceidratio",
+			conf: SamplerConfig{Type: "traceidratio", Ratio: 0},
+			steps: []step{
+				{noParent, "span", sdktrace.Drop},
+			},
+		},
+		{
+			name: "first matching rule wins",
+			conf: SamplerConfig{Type: "always_off", Rules: []SamplerRule{
+				{Span: "health*", Ratio: 0},
+				{Span: "*", Ratio: 1},
+			}},
+			steps: []step{
+				{noParent, "healthcheck", sdktrace.Drop},
+				{noParent, "SayHello", sdktrace.RecordAndSample},
+			},
+		},
+		{
+			name: "rules only apply to root spans with parentbased",
+			conf: SamplerConfig{Type: "parentbased_always_on", Rules: []SamplerRule{{Span: "*", Ratio: 0}}},
+			steps: []step{
+				{noParent, "span", sdktrace.Drop},
+				{sampledParent, "span", sdktrace.RecordAndSample},
+			},
+		},
+		{
+			name: "keepErrors records dropped spans",
+			conf: SamplerConfig{Type: "always_off", KeepErrors: true},
+			steps: []step{
+				{noParent, "span", sdktrace.RecordOnly},
+			},
+		},
+		{
+			name: "rate limit counts root spans only",
+			conf: SamplerConfig{Type: "always_on", RateLimit: 1},
+			steps: []step{
+				{noParent, "root", sdktrace.RecordAndSample},
+				{sampledParent, "child", sdktrace.RecordAndSample},
+				{sampledParent, "child", sdktrace.RecordAndSample},
+				{noParent, "root", sdktrace.Drop},
+			},
+		},
+		{
+			name: "rate limited spans are recorded with keepErrors",
+			conf: SamplerConfig{Type: "always_on", RateLimit: 1, KeepErrors: true},
+			steps: []step{
+				{noParent, "root", sdktrace.RecordAndSample},
+				{noParent, "root", sdktrace.RecordOnly},
+			},
+		},
+		{
+			name: "type from env",
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "always_off"},
+			steps: []step{
+				{sampledParent, "span", sdktrace.Drop},
+			},
+		},
+		{
+			name: "ratio from env",
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "0"},
+			steps: []step{
+				{noParent, "span", sdktrace.Drop},
+			},
+		},
+		{
+			name: "config over env",
+			conf: SamplerConfig{Type: "always_on"},
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "always_off"},
+			steps: []step{
+				{noParent, "span", sdktrace.RecordAndSample},
+			},
+		},
+		{
+			name: "unknown env sampler falls back to the default",
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "sometimes"},
+			steps: []step{
+				{noParent, "span", sdktrace.RecordAndSample},
+				{unsampledParent, "span", sdktrace.Drop},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_SAMPLER", "")
+			t.Setenv("OTEL_TRACES_SAMPLER_ARG", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			sampler := newSampler(tt.conf)
+			for i, step := range tt.steps {
+				got := sampler.ShouldSample(samplingParameters(step.parent, step.name)).Decision
+				if got != step.want {
+					t.Errorf("step %d: got decision %v, want %v", i, got, step.want)
+				}
+			}
+		})
+	}
+}
@@ -5,18 +5,25 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 var _ BoilerplateServer = &boilerplate{}
@@ -27,6 +34,25 @@ type boilerplate struct {
 	grpcRegisterFunc    GrpcRegisterFunc
 	gatewayRegisterFunc GatewayRegisterFunc
 	interceptors        []grpc.UnaryServerInterceptor
+	streamInterceptors  []grpc.StreamServerInterceptor
+	openapiSpecs        []openapiSpec
+	source              *configSource
+	overrides           []func(*BoilerplateConfig)
+	configErr           error
+	subscribers         []ConfigSubscriber
+	cors                *cors
+	metricsHandler      http.Handler
+	requestMetrics      *requestMetrics
+	accessLog           *accessLog
+	recovery            *recovery
+	rateLimiter         *rateLimiter
+	rateLimitStore      RateLimitStore
+	gatewayToken        string
+	reflectionAuth      *reflectionAuth
+	logBackend          logBackend
+	logger              *slog.Logger
+	logLevel            slog.LevelVar
+	mu                  sync.RWMutex
 }
 
 func New() BoilerplateServer {
@@ -41,34 +67,123 @@ func Default() BoilerplateServer {
 
 func (s *boilerplate) Run(ctx context.Context) error {
 
-	if s.config.Otel.Enabled {
-		shutdown, err := setupOtel(ctx, s.config.Otel, s.config.ServiceName)
+	if s.configErr != nil {
+		return s.configErr
+	}
+
+	conf := s.Config()
+
+	if err := conf.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	s.logLevel.Set(conf.Log.level())
+	s.subscribe(func(old, new BoilerplateConfig) {
+		s.logLevel.Set(new.Log.level())
+	})
+
+	backend := s.logBackend
+	if backend == nil {
+		backend = slogBackend{log: slog.New(newLogHandler(conf.Log, os.Stderr, &s.logLevel))}
+	}
+	s.setLogger(backend.logger(conf.Otel.LoggerName, nil))
+
+	if conf.Otel.Enabled {
+		tel, err := setupOtel(ctx, conf.Otel, conf.ServiceName, s.logger)
 		if err != nil {
 			return err
 		}
-		defer shutdown(ctx)
+		defer tel.shutdown(ctx)
+		s.metricsHandler = tel.metricsHandler
+
+		if tel.loggerProvider != nil {
+			s.setLogger(backend.logger(conf.Otel.LoggerName, tel.loggerProvider))
+		}
+
+		if tel.sampler != nil {
+			s.subscribe(func(old, new BoilerplateConfig) {
+				tel.sampler.update(new.Otel.Sampler)
+			})
+		}
 	}
 
 	tp := otel.GetTracerProvider()
-	s.tracer = tp.Tracer(s.config.Otel.TracerName)
+	s.tracer = tp.Tracer(conf.Otel.TracerName)
+
+	if conf.Otel.MetricsEnabled() {
+		metrics, err := newRequestMetrics(otel.GetMeterProvider())
+		if err != nil {
+			return err
+		}
+		s.requestMetrics = metrics
+	}
+
+	if conf.Log.Access.Enabled {
+		s.accessLog = newAccessLog(conf.Log.Access, s.logger)
+	}
+
+	recovery, err := newRecovery(s.logger, otel.GetMeterProvider())
+	if err != nil {
+		return err
+	}
+	s.recovery = recovery
+
+	if conf.RateLimit.Enabled {
+		limiter, err := newRateLimiter(conf.RateLimit, s.rateLimitStore, otel.GetMeterProvider(), s.logger)
+		if err != nil {
+			return err
+		}
+		s.rateLimiter = limiter
+		s.subscribe(func(old, new BoilerplateConfig) {
+			limiter.update(new.RateLimit)
+		})
+	}
+
+	if conf.Grpc.Reflection.Enabled {
+		auth, err := newReflectionAuth(ctx, conf.Grpc.Reflection)
+		if err != nil {
+			return err
+		}
+		s.reflectionAuth = auth
+		s.subscribe(func(old, new BoilerplateConfig) {
+			if reflect.DeepEqual(old.Grpc.Reflection, new.Grpc.Reflection) {
+				return
+			}
+			if err := auth.update(ctx, new.Grpc.Reflection); err != nil {
+				s.logger.Error("could not apply reflection auth settings, keeping the previous ones", "error", err)
+			}
+		})
+	}
+
+	s.gatewayToken = newGatewayToken()
 
 	errChan := make(chan error)
 
 	// if grpc is off, we can have no gateway either
-	if s.config.Grpc.Disabled {
+	if conf.Grpc.Disabled {
 		return nil
 	}
 
 	go func() {
-		errChan <- s.runGrpc()
+		errChan <- s.runGrpc(conf)
 	}()
 
-	if !s.config.Gateway.Disabled {
+	if !conf.Gateway.Disabled {
 		go func() {
-			errChan <- s.runGateway(ctx)
+			errChan <- s.runGateway(ctx, conf)
 		}()
 	}
 
+	if conf.Admin.Enabled {
+		go func() {
+			errChan <- s.runAdmin(conf)
+		}()
+	}
+
+	if s.source != nil && s.source.path != "" && conf.Reload.Enabled {
+		go s.watchConfig(ctx, conf.Reload.Interval)
+	}
+
 	select {
 	case err := <-errChan:
 		return err
@@ -77,11 +192,11 @@ func (s *boilerplate) Run(ctx context.Context) error {
 	}
 }
 
-func (s *boilerplate) runGrpc() error {
+func (s *boilerplate) runGrpc(conf BoilerplateConfig) error {
 	var opts []grpc.ServerOption
 
-	if s.config.Grpc.TLS.Enabled {
-		cert, err := tls.LoadX509KeyPair(s.config.Grpc.TLS.Cert, s.config.Grpc.TLS.Key)
+	if conf.Grpc.TLS.Enabled {
+		cert, err := tls.LoadX509KeyPair(conf.Grpc.TLS.Cert, conf.Grpc.TLS.Key)
 		if err != nil {
 			return err
 		}
@@ -90,9 +205,9 @@ func (s *boilerplate) runGrpc() error {
 			Certificates: []tls.Certificate{cert},
 		}
 
-		if s.config.Grpc.TLS.Mutual {
+		if conf.Grpc.TLS.Mutual {
 			ca := x509.NewCertPool()
-			caBytes, err := os.ReadFile(s.config.Grpc.TLS.Ca)
+			caBytes, err := os.ReadFile(conf.Grpc.TLS.Ca)
 			if err != nil {
 				return err
 			}
@@ -108,38 +223,127 @@ func (s *boilerplate) runGrpc() error {
 		opts = append(opts, grpc.Creds(creds))
 	}
 
-	if s.config.Otel.Tracing.Enabled {
-		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	opts = append(opts, grpcServerOptions(conf.Grpc)...)
+
+	if conf.Otel.TracingEnabled() || conf.Otel.MetricsEnabled() {
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithTracerProvider(otel.GetTracerProvider()),
+			otelgrpc.WithMeterProvider(otel.GetMeterProvider()))))
+	}
+
+	unaryInterceptors := s.interceptors
+	streamInterceptors := s.streamInterceptors
+
+	if s.reflectionAuth != nil {
+		streamInterceptors = append([]grpc.StreamServerInterceptor{s.reflectionAuth.streamInterceptor()}, streamInterceptors...)
 	}
 
-	for _, interceptor := range s.interceptors {
-		opts = append(opts, grpc.UnaryInterceptor(interceptor))
+	// built in interceptors run before the ones added by the service. The
+	// recovery comes last, so the others see the error a panic turned into.
+	if s.recovery != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{s.recovery.unaryInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{s.recovery.streamInterceptor()}, streamInterceptors...)
+	}
+	if s.requestMetrics != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{s.requestMetrics.unaryInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{s.requestMetrics.streamInterceptor()}, streamInterceptors...)
+	}
+	if s.accessLog != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{s.accessLog.unaryInterceptor()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{s.accessLog.streamInterceptor()}, streamInterceptors...)
+	}
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{requestIDUnaryInterceptor()}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{requestIDStreamInterceptor()}, streamInterceptors...)
+	unaryInterceptors = append([]grpc.UnaryServerInterceptor{clientAddressUnaryInterceptor(s.gatewayToken)}, unaryInterceptors...)
+	streamInterceptors = append([]grpc.StreamServerInterceptor{clientAddressStreamInterceptor(s.gatewayToken)}, streamInterceptors...)
+
+	// the rate limit may be keyed by the subject the service's auth
+	// interceptors found
+	if s.rateLimiter != nil {
+		unaryInterceptors = append(unaryInterceptors, s.rateLimiter.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, s.rateLimiter.streamInterceptor())
 	}
 
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+
 	server := grpc.NewServer(opts...)
 	err := s.grpcRegisterFunc(server)
 	if err != nil {
 		return err
 	}
 
-	lis, err := net.Listen("tcp", s.config.Grpc.Addr)
+	if conf.Grpc.Reflection.Enabled {
+		reflection.Register(server)
+	}
+
+	lis, err := net.Listen("tcp", conf.Grpc.Addr)
 	if err != nil {
 		return err
 	}
 
-	logrus.Infof("starting grpc server, listening on '%s'", s.config.Grpc.Addr)
+	s.logger.Info("starting grpc server", "addr", conf.Grpc.Addr)
 	return server.Serve(lis)
 }
 
-func (s *boilerplate) runGateway(ctx context.Context) error {
+func grpcServerOptions(conf GrpcConfig) []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	if conf.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(conf.MaxRecvMsgSize))
+	}
+	if conf.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(conf.MaxSendMsgSize))
+	}
+	if conf.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(conf.MaxConcurrentStreams))
+	}
+	if conf.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(conf.ConnectionTimeout))
+	}
+	if conf.InitialWindowSize > 0 {
+		opts = append(opts, grpc.InitialWindowSize(conf.InitialWindowSize))
+	}
+	if conf.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(conf.InitialConnWindowSize))
+	}
+	if conf.MaxHeaderListSize > 0 {
+		opts = append(opts, grpc.MaxHeaderListSize(conf.MaxHeaderListSize))
+	}
+
+	ka := conf.Keepalive
+	if ka.MinTime > 0 || ka.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             ka.MinTime,
+			PermitWithoutStream: ka.PermitWithoutStream,
+		}))
+	}
+
+	// zero values are replaced with the grpc-go defaults by the server
+	if ka.MaxConnectionIdle > 0 || ka.MaxConnectionAge > 0 || ka.MaxConnectionAgeGrace > 0 || ka.Time > 0 || ka.Timeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     ka.MaxConnectionIdle,
+			MaxConnectionAge:      ka.MaxConnectionAge,
+			MaxConnectionAgeGrace: ka.MaxConnectionAgeGrace,
+			Time:                  ka.Time,
+			Timeout:               ka.Timeout,
+		}))
+	}
+
+	return opts
+}
+
+func (s *boilerplate) runGateway(ctx context.Context, conf BoilerplateConfig) error {
 
 	var dialOptions []grpc.DialOption
 
-	if !s.config.Grpc.TLS.Enabled {
+	if !conf.Grpc.TLS.Enabled {
 		dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	} else {
 		ca := x509.NewCertPool()
-		caBytes, err := os.ReadFile(s.config.Gateway.TLS.Ca)
+		caBytes, err := os.ReadFile(conf.Gateway.TLS.Ca)
 		if err != nil {
 			return err
 		}
@@ -151,8 +355,8 @@ func (s *boilerplate) runGateway(ctx context.Context) error {
 			RootCAs:    ca,
 			ServerName: "joe.mama",
 		}
-		if s.config.Grpc.TLS.Mutual {
-			cert, err := tls.LoadX509KeyPair(s.config.Gateway.TLS.Cert, s.config.Gateway.TLS.Key)
+		if conf.Grpc.TLS.Mutual {
+			cert, err := tls.LoadX509KeyPair(conf.Gateway.TLS.Cert, conf.Gateway.TLS.Key)
 			if err != nil {
 				return err
 			}
@@ -163,14 +367,26 @@ func (s *boilerplate) runGateway(ctx context.Context) error {
 		dialOptions = append(dialOptions, grpc.WithTransportCredentials(creds))
 	}
 
-	/*
-		if s.config.Otel.Tracing.Enabled {
-			dialOptions = append(dialOptions, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
-		}
-	*/
+	// the gateway must accept what the grpc server is allowed to send
+	var callOptions []grpc.CallOption
+	if conf.Grpc.MaxSendMsgSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(conf.Grpc.MaxSendMsgSize))
+	}
+	if conf.Grpc.MaxRecvMsgSize > 0 {
+		callOptions = append(callOptions, grpc.MaxCallSendMsgSize(conf.Grpc.MaxRecvMsgSize))
+	}
+	if len(callOptions) > 0 {
+		dialOptions = append(dialOptions, grpc.WithDefaultCallOptions(callOptions...))
+	}
+
+	if conf.Otel.TracingEnabled() || conf.Otel.MetricsEnabled() {
+		dialOptions = append(dialOptions, grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+			otelgrpc.WithTracerProvider(otel.GetTracerProvider()),
+			otelgrpc.WithMeterProvider(otel.GetMeterProvider()))))
+	}
 
 	conn, err := grpc.NewClient(
-		s.config.Grpc.Addr,
+		conf.Grpc.Addr,
 		dialOptions...,
 	)
 
@@ -178,26 +394,123 @@ func (s *boilerplate) runGateway(ctx context.Context) error {
 		return err
 	}
 
-	mux := runtime.NewServeMux()
+	var forwardHeaders []string
+	if conf.RateLimit.Enabled {
+		forwardHeaders = append(forwardHeaders, conf.RateLimit.apiKeyHeader())
+	}
+
+	muxOptions := []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher(conf.Otel.propagatorNames(), forwardHeaders...)),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMetadata(gatewayMetadata(s.gatewayToken)),
+	}
+
+	instrumented := conf.Otel.TracingEnabled() || conf.Otel.MetricsEnabled()
+	if instrumented || s.accessLog != nil {
+		muxOptions = append(muxOptions, runtime.WithMiddlewares(routeMiddleware))
+	}
+
+	if conf.Gateway.Problem.Enabled {
+		muxOptions = append(muxOptions, runtime.WithErrorHandler(problemErrorHandler(conf.Gateway.Problem, s.logger)))
+	}
+
+	mux := runtime.NewServeMux(muxOptions...)
 
 	err = s.gatewayRegisterFunc(ctx, mux, conn)
 	if err != nil {
 		return err
 	}
 
-	var handler http.Handler = corsHandler(
-		s.config.Gateway.AllowedOrigins,
-		s.config.Gateway.AllowedMethods,
-		s.config.Gateway.AllowedHeaders)(mux)
+	root := http.NewServeMux()
+	root.Handle("/", mux)
+
+	if s.metricsHandler != nil && !conf.Admin.Enabled {
+		root.Handle(conf.Otel.Prometheus.path(), s.metricsHandler)
+	}
+
+	if conf.Gateway.OpenAPI.Enabled {
+		if err := s.registerOpenAPI(root, conf); err != nil {
+			return err
+		}
+	}
+
+	s.cors = newCors(
+		conf.Gateway.AllowedOrigins,
+		conf.Gateway.AllowedMethods,
+		conf.Gateway.AllowedHeaders)
+	s.subscribe(func(old, new BoilerplateConfig) {
+		s.cors.update(new.Gateway.AllowedOrigins, new.Gateway.AllowedMethods, new.Gateway.AllowedHeaders)
+	})
+
+	var handler http.Handler = s.cors.handler(root)
+
+	if conf.Gateway.RequestTimeout > 0 {
+		handler = timeoutHandler(conf.Gateway.RequestTimeout)(handler)
+	}
+
+	if s.recovery != nil {
+		handler = s.recovery.handler(handler)
+	}
+
+	if s.requestMetrics != nil {
+		handler = s.requestMetrics.handler(handler)
+	}
+
+	if s.accessLog != nil {
+		handler = s.accessLog.handler(handler)
+	}
+
+	handler = requestIDHandler(handler)
+
+	if instrumented {
+		handler = otelhttp.NewHandler(handler, "gateway",
+			otelhttp.WithSpanNameFormatter(spanNameFromMethod),
+			otelhttp.WithTracerProvider(otel.GetTracerProvider()),
+			otelhttp.WithMeterProvider(otel.GetMeterProvider()))
+	}
 
 	server := &http.Server{
-		Addr:    s.config.Gateway.Addr,
-		Handler: handler,
+		Addr:              conf.Gateway.Addr,
+		Handler:           handler,
+		ReadTimeout:       conf.Gateway.ReadTimeout,
+		ReadHeaderTimeout: conf.Gateway.readHeaderTimeout(),
+		WriteTimeout:      conf.Gateway.WriteTimeout,
+		IdleTimeout:       conf.Gateway.IdleTimeout,
+		MaxHeaderBytes:    conf.Gateway.MaxHeaderBytes,
 	}
-	logrus.Infof("starting gateway server, listening on '%s'", s.config.Gateway.Addr)
+	s.logger.Info("starting gateway server", "addr", conf.Gateway.Addr)
 	return server.ListenAndServe()
 }
 
+// timeoutHandler sets a deadline on the request context. The gateway passes
+// the context on to the grpc client, so the deadline reaches the server.
+func timeoutHandler(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func (s *boilerplate) Tracer() trace.Tracer {
 	return s.tracer
 }
+
+// Logger returns the logger boilerplate writes to, bridged to otel once Run
+// has set up logging
+func (s *boilerplate) Logger() *slog.Logger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+func (s *boilerplate) setLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = slog.New(newContextHandler(logger.Handler(), &s.logLevel))
+}
@@ -0,0 +1,474 @@
+package boilerplate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MIN_OTEL_INTERVAL = time.Second
+	MAX_OTEL_INTERVAL = time.Hour
+)
+
+var validOtelProtocols = []string{"grpc", "http", "https", "stdout", "prometheus"}
+
+// ConfigError describes a single invalid configuration value
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+type configValidator struct {
+	errs []error
+}
+
+func (v *configValidator) add(field string, format string, args ...any) {
+	v.errs = append(v.errs, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the whole configuration and reports all problems at once
+func (c BoilerplateConfig) Validate() error {
+	v := &configValidator{}
+
+	if !c.Grpc.Disabled {
+		v.addr("grpc.addr", c.Grpc.Addr)
+		v.grpc(c.Grpc)
+
+		if c.Grpc.TLS.Enabled {
+			v.keyPair("grpc.tls", c.Grpc.TLS.Cert, c.Grpc.TLS.Key)
+			if c.Grpc.TLS.Mutual {
+				v.ca("grpc.tls.ca", c.Grpc.TLS.Ca)
+			}
+		}
+	}
+
+	// the gateway is only started alongside the grpc server
+	if !c.Grpc.Disabled && !c.Gateway.Disabled {
+		v.addr("gateway.addr", c.Gateway.Addr)
+		v.gateway(c.Gateway)
+
+		if addrConflict(c.Grpc.Addr, c.Gateway.Addr) {
+			v.add("gateway.addr", "'%s' conflicts with grpc.addr '%s'", c.Gateway.Addr, c.Grpc.Addr)
+		}
+
+		// the gateway dials the grpc server with the gateway's tls settings
+		if c.Grpc.TLS.Enabled {
+			v.ca("gateway.tls.ca", c.Gateway.TLS.Ca)
+			if c.Grpc.TLS.Mutual {
+				v.keyPair("gateway.tls", c.Gateway.TLS.Cert, c.Gateway.TLS.Key)
+			}
+		}
+	}
+
+	if c.Admin.Enabled {
+		v.addr("admin.addr", c.Admin.Addr)
+		if addrConflict(c.Admin.Addr, c.Grpc.Addr) {
+			v.add("admin.addr", "'%s' conflicts with grpc.addr '%s'", c.Admin.Addr, c.Grpc.Addr)
+		}
+		if addrConflict(c.Admin.Addr, c.Gateway.Addr) {
+			v.add("admin.addr", "'%s' conflicts with gateway.addr '%s'", c.Admin.Addr, c.Gateway.Addr)
+		}
+	}
+
+	v.log(c.Log)
+
+	if c.RateLimit.Enabled {
+		v.rateLimit(c.RateLimit)
+	}
+
+	if c.Otel.Enabled {
+		v.otel(c.Otel)
+
+		if c.Otel.MetricsEnabled() && c.Otel.MetricsProtocol() == "prometheus" {
+			if !strings.HasPrefix(c.Otel.Prometheus.path(), "/") {
+				v.add("otel.prometheus.path", "must start with '/', got '%s'", c.Otel.Prometheus.Path)
+			}
+			if !c.Admin.Enabled && (c.Grpc.Disabled || c.Gateway.Disabled) {
+				v.add("otel.metrics.protocol", "prometheus metrics are served on the gateway or the admin listener, but both are disabled")
+			}
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+func (v *configValidator) addr(field, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.add(field, "invalid address '%s', expected host:port", addr)
+		return
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		v.add(field, "invalid port '%s' in '%s'", port, addr)
+	}
+
+	if host != "" && net.ParseIP(host) == nil && !validHostname(host) {
+		v.add(field, "invalid host '%s' in '%s'", host, addr)
+	}
+}
+
+func validHostname(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+	for _, r := range host {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// addrConflict reports whether both addresses would bind the same port
+func addrConflict(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || portA != portB || portA == "0" {
+		return false
+	}
+	return hostA == hostB || isWildcardHost(hostA) || isWildcardHost(hostB)
+}
+
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
+
+func (v *configValidator) file(field, path string) bool {
+	if path == "" {
+		v.add(field, "no file configured")
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add(field, "cannot read '%s': %v", path, err)
+		return false
+	}
+	return true
+}
+
+func (v *configValidator) keyPair(field, cert, key string) {
+	certOk := v.file(field+".cert", cert)
+	keyOk := v.file(field+".key", key)
+	if !certOk || !keyOk {
+		return
+	}
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		v.add(field, "cert '%s' and key '%s' do not form a valid key pair: %v", cert, key, err)
+	}
+}
+
+func (v *configValidator) ca(field, path string) {
+	if !v.file(field, path) {
+		return
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		v.add(field, "cannot read '%s': %v", path, err)
+		return
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+		v.add(field, "'%s' contains no valid PEM certificate", path)
+	}
+}
+
+func (v *configValidator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.add(field, "must not be negative, got %d", value)
+	}
+}
+
+func (v *configValidator) duration(field string, d time.Duration) {
+	if d < 0 {
+		v.add(field, "must not be negative, got %s", d)
+	}
+}
+
+func (v *configValidator) grpc(c GrpcConfig) {
+	v.nonNegative("grpc.maxRecvMsgSize", int64(c.MaxRecvMsgSize))
+	v.nonNegative("grpc.maxSendMsgSize", int64(c.MaxSendMsgSize))
+	v.nonNegative("grpc.initialWindowSize", int64(c.InitialWindowSize))
+	v.nonNegative("grpc.initialConnWindowSize", int64(c.InitialConnWindowSize))
+	v.duration("grpc.connectionTimeout", c.ConnectionTimeout)
+	v.duration("grpc.keepalive.minTime", c.Keepalive.MinTime)
+	v.duration("grpc.keepalive.maxConnectionIdle", c.Keepalive.MaxConnectionIdle)
+	v.duration("grpc.keepalive.maxConnectionAge", c.Keepalive.MaxConnectionAge)
+	v.duration("grpc.keepalive.maxConnectionAgeGrace", c.Keepalive.MaxConnectionAgeGrace)
+	v.duration("grpc.keepalive.time", c.Keepalive.Time)
+	v.duration("grpc.keepalive.timeout", c.Keepalive.Timeout)
+
+	for i, u := range c.Reflection.JwksUrls {
+		v.url(fmt.Sprintf("grpc.reflection.jwksUrls[%d]", i), u)
+	}
+	if len(c.Reflection.JwksUrls) > 0 && c.Reflection.Audience == "" {
+		v.add("grpc.reflection.audience", "required with grpc.reflection.jwksUrls, tokens of any service of the issuer would be accepted")
+	}
+}
+
+func (v *configValidator) gateway(c GatewayConfig) {
+	v.duration("gateway.readTimeout", c.ReadTimeout)
+	v.duration("gateway.readHeaderTimeout", c.ReadHeaderTimeout)
+	v.duration("gateway.writeTimeout", c.WriteTimeout)
+	v.duration("gateway.idleTimeout", c.IdleTimeout)
+	v.duration("gateway.requestTimeout", c.RequestTimeout)
+	v.nonNegative("gateway.maxHeaderBytes", int64(c.MaxHeaderBytes))
+
+	if c.Problem.TypeBaseURL != "" {
+		v.url("gateway.problem.typeBaseUrl", c.Problem.TypeBaseURL)
+	}
+
+	switch c.OpenAPI.UI {
+	case "", "swagger", "redoc":
+	default:
+		v.add("gateway.openapi.ui", "unknown ui '%s', expected one of swagger, redoc", c.OpenAPI.UI)
+	}
+	if c.OpenAPI.UI != "" && !c.OpenAPI.CDN && c.OpenAPI.AssetsURL == "" && c.OpenAPI.assets == nil {
+		v.add("gateway.openapi.cdn", "required for the '%s' ui without gateway.openapi.assetsUrl or WithOpenAPIAssets, no ui bundle is embedded", c.OpenAPI.UI)
+	}
+}
+
+func (v *configValidator) url(field, raw string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		v.add(field, "invalid url '%s'", raw)
+	}
+}
+
+func (v *configValidator) otel(c OtelConfig) {
+	v.interval("otel.interval", c.Interval)
+	v.exporter("otel", c.OtelExporterConfig)
+
+	for i, detector := range c.Resource.Detectors {
+		if !slices.Contains(validResourceDetectors, detector) {
+			v.add(fmt.Sprintf("otel.resource.detectors[%d]", i), "unknown detector '%s', expected one of %v", detector, validResourceDetectors)
+		}
+	}
+
+	v.sampler(c.Sampler)
+
+	for _, name := range sortedKeys(c.Instrumentation.HistogramBuckets) {
+		v.buckets("otel.instrumentation.histogramBuckets."+name, c.Instrumentation.HistogramBuckets[name])
+	}
+
+	for i, view := range c.Views {
+		v.view(fmt.Sprintf("otel.views[%d]", i), view)
+	}
+
+	for i, propagator := range c.Propagators {
+		if !slices.Contains(validPropagators, propagator) {
+			v.add(fmt.Sprintf("otel.propagators[%d]", i), "unknown propagator '%s', expected one of %v", propagator, validPropagators)
+		}
+	}
+
+	signals := []struct {
+		name     string
+		signal   string
+		conf     OtelExporterConfig
+		enabled  bool
+		protocol string
+		addr     string
+		insecure bool
+	}{
+		{"otel.tracing", SIGNAL_TRACES, c.Tracing, c.TracingEnabled(), c.TracingProtocol(), c.TracingAddr(), c.TracingInsecure()},
+		{"otel.metrics", SIGNAL_METRICS, c.Metrics, c.MetricsEnabled(), c.MetricsProtocol(), c.MetricsAddr(), c.MetricsInsecure()},
+		{"otel.logging", SIGNAL_LOGS, c.Logging, c.LoggingEnabled(), c.LoggingProtocol(), c.LoggingAddr(), c.LoggingInsecure()},
+	}
+
+	for _, signal := range signals {
+		if !signal.enabled {
+			continue
+		}
+
+		v.interval(signal.name+".interval", signal.conf.Interval)
+		v.exporter(signal.name, signal.conf)
+
+		if !slices.Contains(validOtelProtocols, signal.protocol) {
+			v.add(signal.name+".protocol", "unknown protocol '%s', expected one of %v", signal.protocol, validOtelProtocols)
+			continue
+		}
+
+		if signal.protocol == "prometheus" && signal.name != "otel.metrics" {
+			v.add(signal.name+".protocol", "prometheus is only supported for metrics")
+			continue
+		}
+
+		if signal.protocol != "stdout" && signal.protocol != "prometheus" {
+			if _, _, err := net.SplitHostPort(signal.addr); err != nil {
+				v.add(signal.name+".addr", "invalid address '%s', expected host:port", signal.addr)
+			}
+			// the exporters drop the tls settings of insecure connections
+			if signal.insecure && c.exporterSettings(signal.signal, signal.conf).TLS.enabled() {
+				v.add(signal.name+".insecure", "conflicts with the tls settings, the exporter would connect without tls")
+			}
+		}
+	}
+}
+
+func (v *configValidator) log(c LogConfig) {
+	if c.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			v.add("log.level", "unknown level '%s', expected one of debug, info, warn, error", c.Level)
+		}
+	}
+	if c.Format != "" && !slices.Contains(validLogFormats, c.Format) {
+		v.add("log.format", "unknown format '%s', expected one of %v", c.Format, validLogFormats)
+	}
+
+	v.ratio("log.access.successRatio", c.Access.SuccessRatio)
+	for i, pattern := range c.Access.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.add(fmt.Sprintf("log.access.exclude[%d]", i), "invalid pattern '%s': %v", pattern, err)
+		}
+	}
+}
+
+func (v *configValidator) rateLimit(c RateLimitConfig) {
+	v.rateLimitKey("rateLimit.key", c.Key)
+	v.rateLimitRule("rateLimit.default", c.Default)
+
+	for i, rule := range c.Rules {
+		field := fmt.Sprintf("rateLimit.rules[%d]", i)
+		if _, err := path.Match(rule.Method, ""); err != nil || rule.Method == "" {
+			v.add(field+".method", "invalid pattern '%s'", rule.Method)
+		}
+		// negative rates are reported by rateLimitRule
+		if rule.Rate == 0 {
+			v.add(field+".rate", "must be positive, got %g", rule.Rate)
+		}
+		v.rateLimitRule(field, rule)
+	}
+}
+
+func (v *configValidator) rateLimitRule(field string, rule RateLimitRule) {
+	if rule.Rate < 0 {
+		v.add(field+".rate", "must not be negative, got %g", rule.Rate)
+	}
+	if rule.Burst < 0 {
+		v.add(field+".burst", "must not be negative, got %d", rule.Burst)
+	}
+	v.rateLimitKey(field+".key", rule.Key)
+}
+
+func (v *configValidator) rateLimitKey(field, key string) {
+	if key != "" && !slices.Contains(validRateLimitKeys, key) {
+		v.add(field, "unknown key '%s', expected one of %v", key, validRateLimitKeys)
+	}
+}
+
+func (v *configValidator) sampler(c SamplerConfig) {
+	if c.Type != "" && !slices.Contains(validSamplers, c.Type) {
+		v.add("otel.sampler.type", "unknown sampler '%s', expected one of %v", c.Type, validSamplers)
+	}
+	v.ratio("otel.sampler.ratio", c.Ratio)
+	if c.Type == "" && c.Ratio != DEFAULT_SAMPLER_RATIO {
+		v.add("otel.sampler.ratio", "requires otel.sampler.type traceidratio or parentbased_traceidratio, without a type OTEL_TRACES_SAMPLER_ARG applies")
+	}
+
+	for i, rule := range c.Rules {
+		field := fmt.Sprintf("otel.sampler.rules[%d]", i)
+		if _, err := path.Match(rule.Span, ""); err != nil {
+			v.add(field+".span", "invalid pattern '%s': %v", rule.Span, err)
+		}
+		v.ratio(field+".ratio", rule.Ratio)
+	}
+
+	if c.RateLimit < 0 {
+		v.add("otel.sampler.rateLimit", "must not be negative, got %g", c.RateLimit)
+	}
+}
+
+func (v *configValidator) view(field string, c ViewConfig) {
+	if c.Instrument == "" && c.Meter == "" {
+		v.add(field, "neither instrument nor meter set, the view would match every instrument")
+	}
+	if c.Rename != "" && strings.ContainsAny(c.Instrument, "*?") {
+		v.add(field+".rename", "cannot rename instruments matched by wildcard '%s'", c.Instrument)
+	}
+	if len(c.AllowAttributes) > 0 && len(c.DenyAttributes) > 0 {
+		v.add(field+".denyAttributes", "has no effect if allowAttributes is set")
+	}
+	if c.Aggregation != "" && !slices.Contains(validAggregations, strings.ToLower(c.Aggregation)) {
+		v.add(field+".aggregation", "unknown aggregation '%s', expected one of %v", c.Aggregation, validAggregations)
+	}
+	v.buckets(field+".buckets", c.Buckets)
+	v.nonNegative(field+".cardinalityLimit", int64(c.CardinalityLimit))
+}
+
+func (v *configValidator) buckets(field string, boundaries []float64) {
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i] <= boundaries[i-1] {
+			v.add(field, "boundaries must be strictly increasing, got %v", boundaries)
+			return
+		}
+	}
+}
+
+func (v *configValidator) ratio(field string, r float64) {
+	if r < 0 || r > 1 {
+		v.add(field, "must be between 0 and 1, got %g", r)
+	}
+}
+
+func (v *configValidator) exporter(field string, c OtelExporterConfig) {
+	if c.TLS.Ca != "" {
+		v.ca(field+".tls.ca", c.TLS.Ca)
+	}
+	if c.TLS.Cert != "" || c.TLS.Key != "" {
+		v.keyPair(field+".tls", c.TLS.Cert, c.TLS.Key)
+	}
+
+	for _, key := range sortedKeys(c.Headers) {
+		if _, err := resolveSecret(c.Headers[key]); err != nil {
+			v.add(fmt.Sprintf("%s.headers.%s", field, key), "%v", err)
+		}
+	}
+
+	switch c.Compression {
+	case "", "gzip", "none":
+	default:
+		v.add(field+".compression", "unknown compression '%s', expected one of gzip, none", c.Compression)
+	}
+
+	v.duration(field+".timeout", c.Timeout)
+	v.duration(field+".retry.initialInterval", c.Retry.InitialInterval)
+	v.duration(field+".retry.maxInterval", c.Retry.MaxInterval)
+	v.duration(field+".retry.maxElapsedTime", c.Retry.MaxElapsedTime)
+
+	if c.URLPath != "" && !strings.HasPrefix(c.URLPath, "/") {
+		v.add(field+".urlPath", "must start with '/', got '%s'", c.URLPath)
+	}
+}
+
+// interval validates configured intervals, zero means unset
+func (v *configValidator) interval(field string, d time.Duration) {
+	if d != 0 && (d < MIN_OTEL_INTERVAL || d > MAX_OTEL_INTERVAL) {
+		v.add(field, "must be between %s and %s, got %s", MIN_OTEL_INTERVAL, MAX_OTEL_INTERVAL, d)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
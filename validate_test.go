@@ -0,0 +1,380 @@
+package boilerplate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self signed certificate and its key
+func writeKeyPair(t *testing.T) (cert, key string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert = writeConfigFile(t, "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	key = writeConfigFile(t, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+	return cert, key
+}
+
+// configErrorFields returns the sorted fields of the ConfigErrors in err
+func configErrorFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %T, want joined errors", err)
+	}
+	var fields []string
+	for _, err := range joined.Unwrap() {
+		var configErr ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("got %T, want ConfigError", err)
+		}
+		fields = append(fields, configErr.Field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+func TestValidate(t *testing.T) {
+	cert, key := writeKeyPair(t)
+	otherCert, otherKey := writeKeyPair(t)
+	notPEM := writeConfigFile(t, "ca.pem", "not a certificate")
+
+	tests := []struct {
+		name   string
+		change func(c *BoilerplateConfig)
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			change: func(c *BoilerplateConfig) {},
+		},
+
+		// addresses and port conflicts
+		{
+			name:   "invalid address",
+			change: func(c *BoilerplateConfig) { c.Grpc.Addr = "localhost" },
+			want:   []string{"grpc.addr"},
+		},
+		{
+			name:   "invalid port",
+			change: func(c *BoilerplateConfig) { c.Gateway.Addr = ":70000" },
+			want:   []string{"gateway.addr"},
+		},
+		{
+			name:   "gateway on the grpc port",
+			change: func(c *BoilerplateConfig) { c.Gateway.Addr = "127.0.0.1" + DEFAULT_GRPC_ADDR },
+			want:   []string{"gateway.addr"},
+		},
+		{
+			name: "admin on the gateway port",
+			change: func(c *BoilerplateConfig) {
+				c.Admin.Enabled = true
+				c.Admin.Addr = DEFAULT_GATEWAY_ADDR
+			},
+			want: []string{"admin.addr"},
+		},
+		{
+			name: "same port on different hosts",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.Addr = "127.0.0.1:9000"
+				c.Gateway.Addr = "127.0.0.2:9000"
+			},
+		},
+		{
+			name: "random ports",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.Addr = ":0"
+				c.Gateway.Addr = ":0"
+			},
+		},
+		{
+			name: "disabled gateway is not checked",
+			change: func(c *BoilerplateConfig) {
+				c.Gateway.Disabled = true
+				c.Gateway.Addr = DEFAULT_GRPC_ADDR
+			},
+		},
+
+		// tls
+		{
+			name: "mutual tls",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.TLS = TlsConfig{Enabled: true, Mutual: true, Cert: cert, Key: key, Ca: otherCert}
+				c.Gateway.TLS = TlsConfig{Cert: otherCert, Key: otherKey, Ca: cert}
+			},
+		},
+		{
+			name: "missing files",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.TLS = TlsConfig{Enabled: true, Cert: "missing.pem"}
+				c.Gateway.TLS = TlsConfig{Ca: "missing.pem"}
+			},
+			want: []string{"gateway.tls.ca", "grpc.tls.cert", "grpc.tls.key"},
+		},
+		{
+			name: "cert and key of different pairs",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.TLS = TlsConfig{Enabled: true, Cert: cert, Key: otherKey}
+				c.Gateway.TLS = TlsConfig{Ca: cert}
+			},
+			want: []string{"grpc.tls"},
+		},
+		{
+			name: "ca without certificates",
+			change: func(c *BoilerplateConfig) {
+				c.Grpc.TLS = TlsConfig{Enabled: true, Mutual: true, Cert: cert, Key: key, Ca: notPEM}
+				c.Gateway.TLS = TlsConfig{Cert: cert, Key: key, Ca: notPEM}
+			},
+			want: []string{"gateway.tls.ca", "grpc.tls.ca"},
+		},
+
+		// otel intervals
+		{
+			name: "interval bounds",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Interval = MIN_OTEL_INTERVAL
+				c.Otel.Tracing.Interval = MAX_OTEL_INTERVAL
+			},
+		},
+		{
+			name: "intervals out of bounds",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Interval = 500 * time.Millisecond
+				c.Otel.Metrics.Interval = 2 * time.Hour
+			},
+			want: []string{"otel.interval", "otel.metrics.interval"},
+		},
+		{
+			name: "disabled signals are not checked",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Logging.Enabled = false
+				c.Otel.Logging.Interval = time.Millisecond
+			},
+		},
+
+		// exporters
+		{
+			name: "exporter tls",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.TLS = OtelTLSConfig{Ca: cert, Cert: otherCert, Key: otherKey}
+			},
+		},
+		{
+			name: "insecure with tls settings",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Tracing.Enabled = true
+				c.Otel.Insecure = true
+				c.Otel.TLS.Ca = cert
+			},
+			want: []string{"otel.logging.insecure", "otel.metrics.insecure", "otel.tracing.insecure"},
+		},
+		{
+			name: "insecure signal with tls settings",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Tracing.Enabled = true
+				c.Otel.Tracing.Insecure = true
+				c.Otel.Tracing.TLS.ServerName = "collector"
+				c.Otel.Metrics.Insecure = true
+			},
+			want: []string{"otel.tracing.insecure"},
+		},
+		{
+			name: "tls settings are not used by stdout exporters",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Protocol = "stdout"
+				c.Otel.Insecure = true
+				c.Otel.TLS.Ca = cert
+			},
+		},
+
+		// sampler
+		{
+			name: "ratio sampler",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Sampler = SamplerConfig{Type: "parentbased_traceidratio", Ratio: 0.1, RateLimit: 10}
+			},
+		},
+		{
+			name: "invalid sampler",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Sampler = SamplerConfig{Type: "sometimes", Ratio: 2, RateLimit: -1}
+			},
+			want: []string{"otel.sampler.rateLimit", "otel.sampler.ratio", "otel.sampler.type"},
+		},
+		{
+			name:   "ratio without type",
+			change: func(c *BoilerplateConfig) { c.Otel.Sampler.Ratio = 0.5 },
+			want:   []string{"otel.sampler.ratio"},
+		},
+		{
+			name: "invalid sampler rules",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Sampler.Rules = []SamplerRule{{Span: "[", Ratio: 0}, {Span: "*", Ratio: -1}}
+			},
+			want: []string{"otel.sampler.rules[0].span", "otel.sampler.rules[1].ratio"},
+		},
+
+		// views
+		{
+			name: "views",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Views = []ViewConfig{
+					{Instrument: "rpc.server.duration", Rename: "grpc.duration", Aggregation: "Histogram", Buckets: []float64{1, 10}},
+					{Meter: "otelhttp", CardinalityLimit: 100},
+				}
+			},
+		},
+		{
+			name: "invalid views",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Views = []ViewConfig{
+					{CardinalityLimit: -1},
+					{Instrument: "rpc.*", Rename: "grpc", AllowAttributes: []string{"a"}, DenyAttributes: []string{"b"}},
+					{Instrument: "rpc.server.duration", Aggregation: "median", Buckets: []float64{10, 1}},
+				}
+			},
+			want: []string{
+				"otel.views[0]", "otel.views[0].cardinalityLimit",
+				"otel.views[1].denyAttributes", "otel.views[1].rename",
+				"otel.views[2].aggregation", "otel.views[2].buckets",
+			},
+		},
+		{
+			name: "histogram buckets",
+			change: func(c *BoilerplateConfig) {
+				c.Otel.Instrumentation.HistogramBuckets = map[string][]float64{"a": {1, 2}, "b": {2, 2}}
+			},
+			want: []string{"otel.instrumentation.histogramBuckets.b"},
+		},
+
+		// rate limits
+		{
+			name: "rate limits",
+			change: func(c *BoilerplateConfig) {
+				c.RateLimit = RateLimitConfig{
+					Enabled: true,
+					Key:     "subject",
+					Default: RateLimitRule{Rate: 10, Burst: 20},
+					Rules:   []RateLimitRule{{Method: "/greeter.v1.GreeterService/*", Rate: 1, Key: "apiKey"}},
+				}
+			},
+		},
+		{
+			name: "invalid rate limits",
+			change: func(c *BoilerplateConfig) {
+				c.RateLimit = RateLimitConfig{
+					Enabled: true,
+					Key:     "ip",
+					Default: RateLimitRule{Rate: -1, Burst: -1},
+					Rules:   []RateLimitRule{{Rate: 0}, {Method: "[", Rate: 1, Key: "user"}},
+				}
+			},
+			want: []string{
+				"rateLimit.default.burst", "rateLimit.default.rate", "rateLimit.key",
+				"rateLimit.rules[0].method", "rateLimit.rules[0].rate",
+				"rateLimit.rules[1].key", "rateLimit.rules[1].method",
+			},
+		},
+		{
+			name: "disabled rate limits are not checked",
+			change: func(c *BoilerplateConfig) {
+				c.RateLimit = RateLimitConfig{Key: "ip"}
+			},
+		},
+
+		// log settings
+		{
+			name: "log settings",
+			change: func(c *BoilerplateConfig) {
+				c.Log = LogConfig{Level: "DEBUG", Format: "json", Access: AccessLogConfig{SuccessRatio: 0, Exclude: []string{"/grpc.health.v1.Health/*"}}}
+			},
+		},
+		{
+			name: "invalid log settings",
+			change: func(c *BoilerplateConfig) {
+				c.Log = LogConfig{Level: "loud", Format: "xml", Access: AccessLogConfig{SuccessRatio: 2, Exclude: []string{"["}}}
+			},
+			want: []string{"log.access.exclude[0]", "log.access.successRatio", "log.format", "log.level"},
+		},
+
+		// reflection and openapi
+		{
+			name:   "reflection tokens without audience",
+			change: func(c *BoilerplateConfig) { c.Grpc.Reflection.JwksUrls = []string{"https://example.com/jwks.json"} },
+			want:   []string{"grpc.reflection.audience"},
+		},
+		{
+			name: "openapi ui without bundle",
+			change: func(c *BoilerplateConfig) {
+				c.Gateway.OpenAPI = OpenAPIConfig{Enabled: true, UI: "swagger"}
+			},
+			want: []string{"gateway.openapi.cdn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := defaultConfig
+			tt.change(&conf)
+			got := configErrorFields(t, conf.Validate())
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got errors for %v, want %v\n%v", got, tt.want, conf.Validate())
+			}
+		})
+	}
+}
+
+func TestAddrConflict(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{":8080", ":8080", true},
+		{":8080", "127.0.0.1:8080", true},
+		{"0.0.0.0:8080", "[::1]:8080", true},
+		{"[::]:8080", "127.0.0.1:8080", true},
+		{"127.0.0.1:8080", "127.0.0.1:8080", true},
+		{"127.0.0.1:8080", "127.0.0.2:8080", false},
+		{":8080", ":8081", false},
+		{":0", ":0", false},
+		{"invalid", ":8080", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := addrConflict(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package boilerplate
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// defaultHistogramBuckets are the boundaries the sdk uses by default
+var defaultHistogramBuckets = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+var validAggregations = []string{"default", "sum", "lastvalue", "histogram", "exponential_histogram"}
+
+// newViews combines the configured views and histogram buckets into a single
+// view, so an instrument matching more than one of them still produces a
+// single stream. The first matching view applies, histogram buckets only
+// apply to instruments without a view. Cardinality limits are applied
+// separately by cardinalityMeterProvider, views only setting a limit are
+// left out.
+func newViews(conf OtelConfig) []sdkmetric.View {
+	var views []sdkmetric.View
+	for _, view := range conf.Views {
+		if view.changesStream() {
+			views = append(views, view.view())
+		}
+	}
+	views = append(views, bucketViews(conf.Instrumentation.HistogramBuckets)...)
+
+	if len(views) == 0 {
+		return nil
+	}
+
+	return []sdkmetric.View{func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		for _, view := range views {
+			if stream, ok := view(i); ok {
+				return stream, true
+			}
+		}
+		return sdkmetric.Stream{}, false
+	}}
+}
+
+func bucketViews(buckets map[string][]float64) []sdkmetric.View {
+	var views []sdkmetric.View
+	for _, name := range sortedKeys(buckets) {
+		views = append(views, sdkmetric.NewView(
+			sdkmetric.Instrument{Name: name, Kind: sdkmetric.InstrumentKindHistogram},
+			sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: buckets[name]}},
+		))
+	}
+	return views
+}
+
+func (c ViewConfig) view() sdkmetric.View {
+	criteria := sdkmetric.Instrument{
+		Name:  c.Instrument,
+		Scope: instrumentation.Scope{Name: c.Meter},
+	}
+
+	mask := sdkmetric.Stream{
+		Name:            c.Rename,
+		Description:     c.Description,
+		Aggregation:     c.aggregation(),
+		AttributeFilter: attributeFilter(c.allowAttributes(), c.DenyAttributes),
+	}
+
+	return sdkmetric.NewView(criteria, mask)
+}
+
+// changesStream reports whether the view changes more than the cardinality
+// limit
+func (c ViewConfig) changesStream() bool {
+	return c.Rename != "" || c.Description != "" || c.Drop ||
+		len(c.AllowAttributes) > 0 || len(c.DenyAttributes) > 0 ||
+		c.Aggregation != "" || len(c.Buckets) > 0
+}
+
+// matches reports whether the view applies to the instrument of the meter,
+// as the sdk matches the view criteria
+func (c ViewConfig) matches(meter, instrument string) bool {
+	if c.Meter != "" && c.Meter != meter {
+		return false
+	}
+	if c.Instrument == "" {
+		return true
+	}
+	ok, _ := path.Match(c.Instrument, instrument)
+	return ok
+}
+
+// allowAttributes keeps the overflow attribute of instruments limited by any
+// view, see cardinalityMeterProvider
+func (c ViewConfig) allowAttributes() []string {
+	if len(c.AllowAttributes) == 0 {
+		return nil
+	}
+	return append(slices.Clone(c.AllowAttributes), "otel.metric.overflow")
+}
+
+func (c ViewConfig) aggregation() sdkmetric.Aggregation {
+	if c.Drop {
+		return sdkmetric.AggregationDrop{}
+	}
+
+	switch strings.ToLower(c.Aggregation) {
+	case "sum":
+		return sdkmetric.AggregationSum{}
+	case "lastvalue":
+		return sdkmetric.AggregationLastValue{}
+	case "exponential_histogram":
+		return sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+	}
+
+	if len(c.Buckets) > 0 {
+		return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: c.Buckets}
+	}
+	if strings.EqualFold(c.Aggregation, "histogram") {
+		return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: defaultHistogramBuckets}
+	}
+	return nil
+}
+
+func attributeFilter(allow, deny []string) attribute.Filter {
+	switch {
+	case len(allow) > 0:
+		return attribute.NewAllowKeysFilter(attributeKeys(allow)...)
+	case len(deny) > 0:
+		return attribute.NewDenyKeysFilter(attributeKeys(deny)...)
+	}
+	return nil
+}
+
+func attributeKeys(names []string) []attribute.Key {
+	keys := make([]attribute.Key, len(names))
+	for i, name := range names {
+		keys[i] = attribute.Key(name)
+	}
+	return keys
+}